@@ -3,8 +3,6 @@ package config
 import (
 	"encoding/json"
 	"migration-tool-go/dtos/common"
-	"migration-tool-go/dtos/destinations/doris"
-	"migration-tool-go/dtos/sources/postgres"
 	"migration-tool-go/logger"
 	"os"
 )
@@ -15,9 +13,28 @@ var (
 	WorkerConfig      common.WorkerConfiguration
 	TrackingConfig    common.TackingConfiguration
 	StatsConfig       common.StatsConfiguration
+	ValidationConfig  common.ValidationConfiguration
+	TransformConfig   common.TransformConfiguration
 )
 
-func InitializeConfig(configPath string) {
+// ValueDecoder parses the "value" block of a source or destination into its typed configuration
+type ValueDecoder func(value json.RawMessage) (any, error)
+
+// DecoderLookup returns the decoder registered for a source or destination type along with the engine
+type DecoderLookup func(engineType string) (ValueDecoder, bool)
+
+// DecodeValue is the ValueDecoder of configurations that unmarshal into T
+func DecodeValue[T any](value json.RawMessage) (any, error) {
+	var typed T
+	if err := json.Unmarshal(value, &typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
+
+// InitializeConfig reads the configuration file, the source and destination blocks are decoded by the decoders
+// their engines registered
+func InitializeConfig(configPath string, sourceDecoder DecoderLookup, destinationDecoder DecoderLookup) {
 
 	// Read the config file using the path
 	configFile, err := os.Open(configPath)
@@ -52,16 +69,18 @@ func InitializeConfig(configPath string) {
 		logger.Sugar.Fatalf("Error extracting source type: %v", err)
 	}
 
-	switch sourceType.Type {
-	case "postgres":
-		var source postgres.Postgres
-		if err := json.Unmarshal(sourceType.Value, &source); err != nil {
-			logger.Sugar.Fatalf("Error parsing common.Source[postgres.Postgres]: %v", err)
-		}
-		SourceConfig.Type = sourceType.Type
-		SourceConfig.Value = source
+	decodeSource, ok := sourceDecoder(sourceType.Type)
+	if !ok {
+		logger.Sugar.Fatalf("Unsupported source type %q", sourceType.Type)
 	}
 
+	source, err := decodeSource(sourceType.Value)
+	if err != nil {
+		logger.Sugar.Fatalf("Error parsing %s source configuration: %v", sourceType.Type, err)
+	}
+	SourceConfig.Type = sourceType.Type
+	SourceConfig.Value = source
+
 	// Step 3: Extract destination type
 	var destType struct {
 		Type  string          `json:"type"`
@@ -71,19 +90,22 @@ func InitializeConfig(configPath string) {
 		logger.Sugar.Fatalf("Error extracting destination type: %v", err)
 	}
 
-	switch destType.Type {
-	case "doris":
-		destination := doris.Doris{}
-		if err := json.Unmarshal(destType.Value, &destination); err != nil {
-			logger.Sugar.Fatalf("Error parsing common.Destination[doris.Doris]: %v", err)
-		}
-		DestinationConfig.Type = destType.Type
-		DestinationConfig.Value = destination
+	decodeDestination, ok := destinationDecoder(destType.Type)
+	if !ok {
+		logger.Sugar.Fatalf("Unsupported destination type %q", destType.Type)
 	}
 
+	destination, err := decodeDestination(destType.Value)
+	if err != nil {
+		logger.Sugar.Fatalf("Error parsing %s destination configuration: %v", destType.Type, err)
+	}
+	DestinationConfig.Type = destType.Type
+	DestinationConfig.Value = destination
+
 	if err := json.Unmarshal(raw["worker_configuration"], &WorkerConfig); err != nil {
 		logger.Sugar.Fatalf("Error extracting worker configuration: %v", err)
 	}
+	WorkerConfig.SetDefaults()
 
 	if err := json.Unmarshal(raw["tracking_configuration"], &TrackingConfig); err != nil {
 		logger.Sugar.Fatalf("Error extracting tracking configuration: %v", err)
@@ -96,6 +118,21 @@ func InitializeConfig(configPath string) {
 		}
 	}
 
+	// Parse validation configuration if it exists
+	if validationConfigRaw, ok := raw["validation_configuration"]; ok {
+		if err := json.Unmarshal(validationConfigRaw, &ValidationConfig); err != nil {
+			logger.Sugar.Fatalf("Error extracting validation configuration: %v", err)
+		}
+	}
+
+	// Parse transform configuration if it exists
+	if transformConfigRaw, ok := raw["transform_configuration"]; ok {
+		if err := json.Unmarshal(transformConfigRaw, &TransformConfig); err != nil {
+			logger.Sugar.Fatalf("Error extracting transform configuration: %v", err)
+		}
+	}
+	TransformConfig.SetDefaults()
+
 	logger.Sugar.Info("Configuration loaded successfully")
 
 }
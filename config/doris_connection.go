@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"migration-tool-go/dtos/destinations/doris"
+	"migration-tool-go/logger"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NewDorisQueryConnection opens a connection to the MySQL protocol port of the first reachable Doris FE
+func NewDorisQueryConnection(ctx context.Context, connectionDetails doris.ConnectionDetails) (*sql.DB, error) {
+	addresses := connectionDetails.GetFeQueryAddresses()
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no Doris FE node configured")
+	}
+
+	var errs []error
+	for _, address := range addresses {
+		db, err := openDorisQueryConnection(ctx, connectionDetails, address)
+		if err == nil {
+			return db, nil
+		}
+		logger.Sugar.Warnf("Doris FE %s is unreachable: %v", address, err)
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func openDorisQueryConnection(ctx context.Context, connectionDetails doris.ConnectionDetails, address string) (*sql.DB, error) {
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.User = connectionDetails.Username
+	mysqlConfig.Passwd = connectionDetails.Password
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = address
+	// The FE does not support every server side prepared statement, bind parameters client side
+	mysqlConfig.InterpolateParams = true
+
+	connector, err := mysql.NewConnector(mysqlConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Doris FE connection: %w", err)
+	}
+
+	db := sql.OpenDB(connector)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to Doris FE %s: %w", mysqlConfig.Addr, err)
+	}
+
+	return db, nil
+}
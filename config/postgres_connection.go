@@ -6,6 +6,7 @@ import (
 	"log"
 	"migration-tool-go/dtos/sources/postgres"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -49,20 +50,7 @@ func NewPostgresConnection(connectionDetails postgres.ConnectionDetails, configu
 
 // Connect establishes the database connection
 func (p *PostgresConnection) Connect(ctx context.Context) error {
-	// Always use 'prefer' as the default SSL mode since the ConnectionDetails doesn't have an SSLMode field
-	const sslMode = "prefer"
-
-	// Build connection string
-	postgresDSN := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		p.connectionDetails.Username,
-		p.connectionDetails.Password,
-		p.connectionDetails.Host,
-		p.connectionDetails.Port,
-		p.connectionDetails.Database,
-		sslMode,
-	)
-
-	poolConfig, err := pgxpool.ParseConfig(postgresDSN)
+	poolConfig, err := pgxpool.ParseConfig(buildDSN(p.connectionDetails))
 	if err != nil {
 		return fmt.Errorf("failed to parse PostgreSQL DSN: %w", err)
 	}
@@ -106,6 +94,31 @@ func (p *PostgresConnection) GetPool() *pgxpool.Pool {
 	return p.pool
 }
 
+// NewReplicationConnection opens a logical replication connection to the source database
+func NewReplicationConnection(ctx context.Context, connectionDetails postgres.ConnectionDetails) (*pgconn.PgConn, error) {
+	conn, err := pgconn.Connect(ctx, buildDSN(connectionDetails)+"&replication=database")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replication connection: %w", err)
+	}
+
+	return conn, nil
+}
+
+// buildDSN builds the connection string for the given connection details
+func buildDSN(connectionDetails postgres.ConnectionDetails) string {
+	// Always use 'prefer' as the default SSL mode since the ConnectionDetails doesn't have an SSLMode field
+	const sslMode = "prefer"
+
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
+		connectionDetails.Username,
+		connectionDetails.Password,
+		connectionDetails.Host,
+		connectionDetails.Port,
+		connectionDetails.Database,
+		sslMode,
+	)
+}
+
 // NewConnection creates a new connection from the postgres configuration (legacy method)
 func NewConnection(postgres postgres.Postgres, numWorkers int) *pgxpool.Pool {
 	// Use connection details directly
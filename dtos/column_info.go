@@ -11,5 +11,7 @@ type ColumnInfo struct {
 	Precision         sql.NullInt64 `json:"precision"`
 	Scale             sql.NullInt64 `json:"scale"`
 	DatetimePrecision sql.NullInt64 `json:"datetime_precision"`
+	MaxLength         sql.NullInt64 `json:"max_length"`
+	IsNullable        bool          `json:"is_nullable"`
 	IsPrimaryKey      bool          `json:"is_primary_key"`
 }
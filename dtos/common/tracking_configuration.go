@@ -2,4 +2,13 @@ package common
 
 type TackingConfiguration struct {
 	ProgressTicker string `json:"progress_ticker"`
+	StateFile      string `json:"state_file"`
+}
+
+// GetStateFile returns the path of the checkpoint state file
+func (t *TackingConfiguration) GetStateFile() string {
+	if t.StateFile == "" {
+		return "state/migration_state.json" // Default state file
+	}
+	return t.StateFile
 }
@@ -0,0 +1,170 @@
+package common
+
+import (
+	"fmt"
+	"os"
+)
+
+// Rules of a column transform
+const (
+	// TransformDrop leaves the column out of the extraction and the load
+	TransformDrop = "drop"
+	// TransformNull loads NULL instead of the value
+	TransformNull = "null"
+	// TransformMask replaces every value with a fixed mask
+	TransformMask = "mask"
+	// TransformKeepLast masks every character but the last ones
+	TransformKeepLast = "keep_last"
+	// TransformHash replaces the value with the hex encoded SHA-256 of the salt followed by the value
+	TransformHash = "hash"
+	// TransformTokenize replaces every letter and digit with a salted pseudo random one of the same class
+	TransformTokenize = "tokenize"
+	// TransformFake replaces the value with a fake value of its kind derived from the salted value
+	TransformFake = "fake"
+)
+
+// Kinds of fake values
+const (
+	FakeName      = "name"
+	FakeFirstName = "first_name"
+	FakeLastName  = "last_name"
+	FakeEmail     = "email"
+	FakePhone     = "phone"
+)
+
+// TransformConfiguration holds the transforms applied to the extracted values of columns before they are loaded
+type TransformConfiguration struct {
+	// Salt keys the hash, tokenize and fake rules, SaltEnv names an environment variable holding it instead
+	Salt    string `json:"salt"`
+	SaltEnv string `json:"salt_env"`
+	// AuditFile is the path of the summary of the transformed columns written at the end of every run
+	AuditFile string            `json:"audit_file"`
+	Tables    []TableTransforms `json:"tables"`
+}
+
+// TableTransforms lists the column transforms and the expressions of a table
+type TableTransforms struct {
+	Schema  string            `json:"schema"`
+	Table   string            `json:"table"`
+	Columns []ColumnTransform `json:"columns"`
+	// Derived are the columns computed from every record, in order, so a derived column can use the ones before it
+	Derived []DerivedColumn `json:"derived"`
+	// DropWhen is a boolean expression, the records it is true for are not loaded
+	DropWhen string `json:"drop_when"`
+}
+
+// DerivedColumn is a column computed by an expression over the columns of a record
+type DerivedColumn struct {
+	Column     string `json:"column"`
+	Expression string `json:"expression"`
+	// Type is the PostgreSQL type the value is loaded and created as, text by default
+	Type string `json:"type"`
+}
+
+// ColumnTransform is the rule applied to a column
+type ColumnTransform struct {
+	Column string `json:"column"`
+	Rule   string `json:"rule"`
+	// Mask replaces the values of the mask rule, **** by default
+	Mask string `json:"mask"`
+	// KeepLast is the number of trailing characters left by the keep_last rule
+	KeepLast int `json:"keep_last"`
+	// Fake is the kind of value of the fake rule: name, first_name, last_name, email or phone
+	Fake string `json:"fake"`
+}
+
+// GetAuditFile returns the path of the transform audit
+func (t *TransformConfiguration) GetAuditFile() string {
+	if t.AuditFile == "" {
+		return "transform_audit.json" // Default audit file
+	}
+	return t.AuditFile
+}
+
+// GetSalt returns the configured salt, read from the environment when salt_env is set
+func (t *TransformConfiguration) GetSalt() string {
+	if t.SaltEnv != "" {
+		return os.Getenv(t.SaltEnv)
+	}
+	return t.Salt
+}
+
+// GetTransforms returns the column transforms of a table
+func (t *TransformConfiguration) GetTransforms(schema string, table string) []ColumnTransform {
+	transforms, _ := t.GetTableTransforms(schema, table)
+	return transforms.Columns
+}
+
+// GetTableTransforms returns the column transforms and expressions of a table, ok is false when it has none
+func (t *TransformConfiguration) GetTableTransforms(schema string, table string) (TableTransforms, bool) {
+	for _, transforms := range t.Tables {
+		if transforms.Schema == schema && transforms.Table == table {
+			return transforms, true
+		}
+	}
+	return TableTransforms{}, false
+}
+
+// SetDefaults sets default values for optional fields
+func (t *TransformConfiguration) SetDefaults() {
+	for i := range t.Tables {
+		for j := range t.Tables[i].Derived {
+			if t.Tables[i].Derived[j].Type == "" {
+				t.Tables[i].Derived[j].Type = "text"
+			}
+		}
+	}
+}
+
+// Validate checks the rules and their parameters, that the keyed rules have a salt and that every derived
+// column has a name and an expression
+func (t *TransformConfiguration) Validate() error {
+	for _, transforms := range t.Tables {
+		derived := make(map[string]bool)
+		for _, column := range transforms.Derived {
+			if column.Column == "" || column.Expression == "" {
+				return fmt.Errorf("derived column of %s.%s requires a column and an expression", transforms.Schema, transforms.Table)
+			}
+			if derived[column.Column] {
+				return fmt.Errorf("derived column %s.%s.%s is defined more than once", transforms.Schema, transforms.Table, column.Column)
+			}
+			derived[column.Column] = true
+		}
+
+		columns := make(map[string]bool)
+		for _, transform := range transforms.Columns {
+			name := fmt.Sprintf("%s.%s.%s", transforms.Schema, transforms.Table, transform.Column)
+			if transform.Column == "" {
+				return fmt.Errorf("transform of %s.%s has no column", transforms.Schema, transforms.Table)
+			}
+			if columns[transform.Column] {
+				return fmt.Errorf("column %s has more than one transform", name)
+			}
+			columns[transform.Column] = true
+
+			switch transform.Rule {
+			case TransformDrop, TransformNull, TransformMask:
+			case TransformKeepLast:
+				if transform.KeepLast <= 0 {
+					return fmt.Errorf("keep_last transform of %s requires a positive keep_last", name)
+				}
+			case TransformHash, TransformTokenize:
+				if t.GetSalt() == "" {
+					return fmt.Errorf("%s transform of %s requires a salt", transform.Rule, name)
+				}
+			case TransformFake:
+				switch transform.Fake {
+				case FakeName, FakeFirstName, FakeLastName, FakeEmail, FakePhone:
+				default:
+					return fmt.Errorf("unknown fake kind %q of %s, expected name, first_name, last_name, email or phone", transform.Fake, name)
+				}
+				if t.GetSalt() == "" {
+					return fmt.Errorf("fake transform of %s requires a salt", name)
+				}
+			default:
+				return fmt.Errorf("unknown transform rule %q of %s", transform.Rule, name)
+			}
+		}
+	}
+	return nil
+}
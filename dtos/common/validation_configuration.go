@@ -0,0 +1,37 @@
+package common
+
+// ValidationConfiguration holds the settings of the validate command and of the post table validation
+type ValidationConfiguration struct {
+	// AfterMigration validates every table as soon as the migrate command has loaded it
+	AfterMigration bool `json:"after_migration"`
+	// CountOnly skips the key range checksums and only compares row counts
+	CountOnly bool `json:"count_only"`
+	// Columns restricts the checksummed columns of a table, every column is checksummed by default
+	Columns    []ValidationColumns `json:"columns"`
+	ReportFile string              `json:"report_file"`
+}
+
+// ValidationColumns lists the checksummed columns of a table
+type ValidationColumns struct {
+	Schema  string   `json:"schema"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+}
+
+// GetReportFile returns the path of the validation report
+func (v *ValidationConfiguration) GetReportFile() string {
+	if v.ReportFile == "" {
+		return "validation_report.json" // Default report file
+	}
+	return v.ReportFile
+}
+
+// GetColumns returns the checksummed columns of a table, ok is false when every column is checksummed
+func (v *ValidationConfiguration) GetColumns(schema string, table string) ([]string, bool) {
+	for _, columns := range v.Columns {
+		if columns.Schema == schema && columns.Table == table {
+			return columns.Columns, true
+		}
+	}
+	return nil, false
+}
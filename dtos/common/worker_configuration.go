@@ -8,3 +8,27 @@ type WorkerConfiguration struct {
 	BatchProcessingTimeoutMs int `json:"batch_processing_timeout_ms"`
 	RecordBatchSize       int `json:"record_batch_size"`
 }
+
+// SetDefaults sets default values for the fields left empty
+func (w *WorkerConfiguration) SetDefaults() {
+	// WorkerBatchSize: Number of workers to use for processing
+	if w.WorkerBatchSize <= 0 {
+		w.WorkerBatchSize = 10000 // Default worker pool size
+	}
+	// IdBatchSize: Batch size for fetching primary key IDs from the source
+	if w.IdBatchSize <= 0 {
+		w.IdBatchSize = 10000 // Default ID batch size
+	}
+	// ConcurrentTables: Number of tables to process concurrently
+	if w.ConcurrentTables <= 0 {
+		w.ConcurrentTables = 10 // Default concurrent tables
+	}
+	// BatchProcessingTimeoutMs: Timeout in milliseconds for batch processing
+	if w.BatchProcessingTimeoutMs <= 0 {
+		w.BatchProcessingTimeoutMs = 500 // Default 500ms timeout
+	}
+	// RecordBatchSize: Number of records to process in a single batch
+	if w.RecordBatchSize <= 0 {
+		w.RecordBatchSize = 5000 // Default record batch size
+	}
+}
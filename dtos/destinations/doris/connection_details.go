@@ -1,11 +1,66 @@
 package doris
 
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
 type ConnectionDetails struct {
-	FeNodes  string `json:"fe_nodes"`
-	FePort   int    `json:"fe_port"`
-	BeNodes  string `json:"be_nodes"`
-	BePort   int    `json:"be_port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Database string `json:"database"`
+	// FeNodes and BeNodes are comma separated hosts, a host may carry its own port as host:port
+	FeNodes     string `json:"fe_nodes"`
+	FePort      int    `json:"fe_port"`
+	FeQueryPort int    `json:"fe_query_port"`
+	BeNodes     string `json:"be_nodes"`
+	BePort      int    `json:"be_port"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Database    string `json:"database"`
+}
+
+// GetFeQueryPort returns the MySQL protocol port of the FE used to run DDL, 9030 by default
+func (c ConnectionDetails) GetFeQueryPort() int {
+	if c.FeQueryPort <= 0 {
+		return 9030
+	}
+	return c.FeQueryPort
+}
+
+// GetFeAddresses returns the HTTP address of every FE node
+func (c ConnectionDetails) GetFeAddresses() []string {
+	return nodeAddresses(c.FeNodes, c.FePort)
+}
+
+// GetBeAddresses returns the HTTP address of every BE node
+func (c ConnectionDetails) GetBeAddresses() []string {
+	return nodeAddresses(c.BeNodes, c.BePort)
+}
+
+// GetFeQueryAddresses returns the MySQL protocol address of every FE node, a port given in fe_nodes is the HTTP port and is ignored
+func (c ConnectionDetails) GetFeQueryAddresses() []string {
+	var addresses []string
+	for _, address := range c.GetFeAddresses() {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(c.GetFeQueryPort())))
+	}
+	return addresses
+}
+
+// nodeAddresses splits a comma separated node list into host:port addresses
+func nodeAddresses(nodes string, defaultPort int) []string {
+	var addresses []string
+	for _, node := range strings.Split(nodes, ",") {
+		node = strings.TrimSpace(node)
+		if node == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(node); err != nil {
+			node = net.JoinHostPort(node, strconv.Itoa(defaultPort))
+		}
+		addresses = append(addresses, node)
+	}
+	return addresses
 }
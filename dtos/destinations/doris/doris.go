@@ -1,10 +1,83 @@
 package doris
 
+import "time"
+
+// DeleteSignColumn is the hidden column of Unique Key tables that marks a row as deleted when set to 1
+const DeleteSignColumn = "__DORIS_DELETE_SIGN__"
+
 type Doris struct {
 	ConnectionDetails ConnectionDetails `json:"connection_details"`
 	Configuration     Configuration     `json:"configuration"`
 }
 
+// Routes of Stream Loads
+const (
+	// LoadRoutingBe spreads loads round robin over the BE nodes
+	LoadRoutingBe = "be"
+	// LoadRoutingFe sends loads to the FE nodes, which redirect them to a BE
+	LoadRoutingFe = "fe"
+)
+
 type Configuration struct {
-	Pool int `json:"pool"`
+	// Pool caps the HTTP connections per node, 10 by default
+	Pool   int                 `json:"pool"`
+	Schema SchemaConfiguration `json:"schema"`
+	Retry  RetryConfiguration  `json:"retry"`
+	Load   LoadConfiguration   `json:"load"`
+	// Mapping names the Doris database and table of every source table
+	Mapping MappingConfiguration `json:"mapping"`
+	// LoadRouting is be or fe, be when BE nodes are configured
+	LoadRouting string `json:"load_routing"`
+	// NodeEjectionMs is how long a failed node is skipped before it is health checked
+	NodeEjectionMs int `json:"node_ejection_ms"`
+	// ConnectTimeoutMs bounds the connection to a node, ResponseTimeoutMs the wait for the answer to a
+	// request once it is sent, which for a Stream Load includes the load itself
+	ConnectTimeoutMs  int `json:"connect_timeout_ms"`
+	ResponseTimeoutMs int `json:"response_timeout_ms"`
+	// TwoPhaseCommit commits the loads of every table at once, Tables enables it per table
+	TwoPhaseCommit bool           `json:"two_phase_commit"`
+	Tables         []TableOptions `json:"tables"`
+}
+
+// GetLoadRouting returns the configured route of Stream Loads, through the BE nodes when they are known
+func (c Configuration) GetLoadRouting(connectionDetails ConnectionDetails) string {
+	if c.LoadRouting != "" {
+		return c.LoadRouting
+	}
+	if len(connectionDetails.GetBeAddresses()) > 0 {
+		return LoadRoutingBe
+	}
+	return LoadRoutingFe
+}
+
+// GetNodeEjectionMs returns how long a failed node is skipped, 10 seconds by default
+func (c Configuration) GetNodeEjectionMs() int {
+	if c.NodeEjectionMs <= 0 {
+		return 10000
+	}
+	return c.NodeEjectionMs
+}
+
+// GetPool returns the HTTP connections per node, 10 by default
+func (c Configuration) GetPool() int {
+	if c.Pool <= 0 {
+		return 10
+	}
+	return c.Pool
+}
+
+// GetConnectTimeout returns the connection timeout, 10 seconds by default
+func (c Configuration) GetConnectTimeout() time.Duration {
+	if c.ConnectTimeoutMs <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.ConnectTimeoutMs) * time.Millisecond
+}
+
+// GetResponseTimeout returns how long a sent request waits for its answer, 10 minutes by default
+func (c Configuration) GetResponseTimeout() time.Duration {
+	if c.ResponseTimeoutMs <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.ResponseTimeoutMs) * time.Millisecond
 }
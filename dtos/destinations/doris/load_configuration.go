@@ -0,0 +1,121 @@
+package doris
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Stream Load payload formats
+const (
+	// LoadFormatJson sends every batch as one JSON array
+	LoadFormatJson = "json"
+	// LoadFormatNdjson sends one JSON object per line
+	LoadFormatNdjson = "ndjson"
+	// LoadFormatCsv sends delimited text in the column order of the source table
+	LoadFormatCsv = "csv"
+)
+
+// Stream Load payload compressions, named after the compress_type values of Doris
+const (
+	LoadCompressionNone = ""
+	LoadCompressionGzip = "gz"
+	LoadCompressionLz4  = "lz4"
+)
+
+// LoadConfiguration holds the format and compression of Stream Load payloads
+type LoadConfiguration struct {
+	// Format is json, ndjson or csv, json by default
+	Format string `json:"format"`
+	// ColumnSeparator and LineDelimiter apply to csv, they accept the \xHH notation of Doris
+	ColumnSeparator string `json:"column_separator"`
+	LineDelimiter   string `json:"line_delimiter"`
+	// Compression is gz, lz4 or empty for uncompressed payloads
+	Compression string `json:"compression"`
+	// BufferSizeBytes is the size of the buffer between the encoder and the request body, 1 MiB by default
+	BufferSizeBytes int `json:"buffer_size_bytes"`
+}
+
+// SetDefaults sets default values for optional fields
+func (l *LoadConfiguration) SetDefaults() {
+	if l.Format == "" {
+		l.Format = LoadFormatJson
+	}
+	if l.ColumnSeparator == "" {
+		l.ColumnSeparator = "\t"
+	}
+	if l.LineDelimiter == "" {
+		l.LineDelimiter = "\n"
+	}
+	if l.BufferSizeBytes <= 0 {
+		l.BufferSizeBytes = 1 << 20
+	}
+}
+
+// Validate checks the format, the compression and the csv separators
+func (l LoadConfiguration) Validate() error {
+	switch l.Format {
+	case LoadFormatJson, LoadFormatNdjson, LoadFormatCsv:
+	default:
+		return fmt.Errorf("unknown load format %q, expected %s, %s or %s", l.Format, LoadFormatJson, LoadFormatNdjson, LoadFormatCsv)
+	}
+
+	switch l.Compression {
+	case LoadCompressionNone, LoadCompressionGzip, LoadCompressionLz4:
+	default:
+		return fmt.Errorf("unknown load compression %q, expected %s or %s", l.Compression, LoadCompressionGzip, LoadCompressionLz4)
+	}
+
+	if l.Format != LoadFormatCsv {
+		return nil
+	}
+	columnSeparator, err := DecodeSeparator(l.ColumnSeparator)
+	if err != nil {
+		return fmt.Errorf("invalid column separator: %w", err)
+	}
+	lineDelimiter, err := DecodeSeparator(l.LineDelimiter)
+	if err != nil {
+		return fmt.Errorf("invalid line delimiter: %w", err)
+	}
+	if columnSeparator == "" || lineDelimiter == "" || strings.Contains(columnSeparator, lineDelimiter) || strings.Contains(lineDelimiter, columnSeparator) {
+		return fmt.Errorf("column separator %q and line delimiter %q must be non empty and distinct", l.ColumnSeparator, l.LineDelimiter)
+	}
+	if strings.ContainsAny(columnSeparator+lineDelimiter, `"\`) {
+		return fmt.Errorf(`column separator and line delimiter cannot contain the enclose character " or the escape character \`)
+	}
+	return nil
+}
+
+// DecodeSeparator resolves the \xHH sequences of a separator into the bytes they stand for
+func DecodeSeparator(separator string) (string, error) {
+	var decoded strings.Builder
+	for i := 0; i < len(separator); i++ {
+		if strings.HasPrefix(separator[i:], `\x`) {
+			if i+4 > len(separator) {
+				return "", fmt.Errorf("truncated escape in %q", separator)
+			}
+			value, err := strconv.ParseUint(separator[i+2:i+4], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape in %q: %w", separator, err)
+			}
+			decoded.WriteByte(byte(value))
+			i += 3
+			continue
+		}
+		decoded.WriteByte(separator[i])
+	}
+	return decoded.String(), nil
+}
+
+// EncodeSeparator renders a separator for a Stream Load header, control characters use the \xHH notation
+func EncodeSeparator(separator string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(separator); i++ {
+		if b := separator[i]; b < 0x20 || b == 0x7f {
+			fmt.Fprintf(&encoded, `\x%02x`, b)
+			continue
+		}
+		encoded.WriteByte(separator[i])
+	}
+	return encoded.String()
+}
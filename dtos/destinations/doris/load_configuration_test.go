@@ -0,0 +1,68 @@
+package doris
+
+import "testing"
+
+func TestSeparators(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  string
+		decoded string
+		encoded string
+	}{
+		{"tab", "\t", "\t", `\x09`},
+		{"escaped control character", `\x01`, "\x01", `\x01`},
+		{"upper case escape", `\x1F`, "\x1f", `\x1f`},
+		{"multi byte", "||", "||", "||"},
+		{"escape between text", `a\x02b`, "a\x02b", `a\x02b`},
+		{"printable escape", `\x7c`, "|", "|"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := DecodeSeparator(tc.config)
+			if err != nil {
+				t.Fatalf("DecodeSeparator(%q): %v", tc.config, err)
+			}
+			if decoded != tc.decoded {
+				t.Errorf("DecodeSeparator(%q) = %q, want %q", tc.config, decoded, tc.decoded)
+			}
+			if encoded := EncodeSeparator(decoded); encoded != tc.encoded {
+				t.Errorf("EncodeSeparator(%q) = %q, want %q", decoded, encoded, tc.encoded)
+			}
+		})
+	}
+
+	for _, invalid := range []string{`\x0`, `\x`, `\xzz`} {
+		if _, err := DecodeSeparator(invalid); err == nil {
+			t.Errorf("DecodeSeparator(%q) accepted an invalid escape", invalid)
+		}
+	}
+}
+
+func TestLoadConfigurationValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  LoadConfiguration
+		wantErr bool
+	}{
+		{"defaults", LoadConfiguration{}, false},
+		{"csv defaults", LoadConfiguration{Format: LoadFormatCsv}, false},
+		{"csv control characters", LoadConfiguration{Format: LoadFormatCsv, ColumnSeparator: `\x01`, LineDelimiter: `\x02`}, false},
+		{"same separators", LoadConfiguration{Format: LoadFormatCsv, ColumnSeparator: `\x01`, LineDelimiter: "\x01"}, true},
+		{"separator inside delimiter", LoadConfiguration{Format: LoadFormatCsv, ColumnSeparator: "|", LineDelimiter: "|\n"}, true},
+		{"enclose character", LoadConfiguration{Format: LoadFormatCsv, ColumnSeparator: `"`}, true},
+		{"escape character", LoadConfiguration{Format: LoadFormatCsv, ColumnSeparator: `\x5c`}, true},
+		{"invalid escape", LoadConfiguration{Format: LoadFormatCsv, ColumnSeparator: `\xg1`}, true},
+		{"unknown format", LoadConfiguration{Format: "parquet"}, true},
+		{"unknown compression", LoadConfiguration{Compression: "zstd"}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.SetDefaults()
+			if err := tc.config.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package doris
+
+// Load states returned by the get_load_state API of the FE
+const (
+	LoadStateUnknown = "UNKNOWN"
+	LoadStatePrepare = "PREPARE"
+	// LoadStatePrecommitted is a two phase commit load waiting for its commit or abort
+	LoadStatePrecommitted = "PRECOMMITTED"
+	LoadStateCommitted    = "COMMITTED"
+	LoadStateVisible      = "VISIBLE"
+	LoadStateAborted      = "ABORTED"
+)
+
+// LoadStateResponse is the JSON body of a get_load_state response, Data holds the state of the label
+type LoadStateResponse struct {
+	Msg  string `json:"msg"`
+	Code int    `json:"code"`
+	Data string `json:"data"`
+}
+
+// Operations of the two phase commit API of the FE
+const (
+	TxnOperationCommit = "commit"
+	TxnOperationAbort  = "abort"
+)
+
+// TwoPhaseCommitResponse is the JSON body of a _stream_load_2pc response
+type TwoPhaseCommitResponse struct {
+	Status string `json:"status"`
+	Msg    string `json:"msg"`
+}
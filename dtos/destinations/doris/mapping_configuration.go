@@ -0,0 +1,101 @@
+package doris
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cases of the Doris table names built from the table template
+const (
+	NameCasePreserve = "preserve"
+	NameCaseLower    = "lower"
+	NameCaseUpper    = "upper"
+)
+
+// Placeholders of the table template
+const (
+	PlaceholderSchema = "{schema}"
+	PlaceholderTable  = "{table}"
+)
+
+// MappingConfiguration holds how source tables are named in Doris
+type MappingConfiguration struct {
+	// Databases maps source schemas to Doris databases, the other schemas go to the database of the connection
+	Databases map[string]string `json:"databases"`
+	// TableTemplate builds the Doris table name from {schema} and {table}, {table} by default
+	TableTemplate string `json:"table_template"`
+	// Case is preserve, lower or upper, applied to the databases and to the names built from the template
+	Case string `json:"case"`
+}
+
+// TableTarget is the Doris database and table a source table is loaded into
+type TableTarget struct {
+	Database string
+	Table    string
+}
+
+func (t TableTarget) String() string {
+	return t.Database + "." + t.Table
+}
+
+// SetDefaults sets default values for optional fields
+func (m *MappingConfiguration) SetDefaults() {
+	if m.TableTemplate == "" {
+		m.TableTemplate = PlaceholderTable
+	}
+	if m.Case == "" {
+		m.Case = NameCasePreserve
+	}
+}
+
+// Validate checks the case and that the template names every table apart
+func (m MappingConfiguration) Validate() error {
+	switch m.Case {
+	case NameCasePreserve, NameCaseLower, NameCaseUpper:
+	default:
+		return fmt.Errorf("unknown mapping case %q, expected %s, %s or %s", m.Case, NameCasePreserve, NameCaseLower, NameCaseUpper)
+	}
+
+	if !strings.Contains(m.TableTemplate, PlaceholderTable) {
+		return fmt.Errorf("table template %q must contain %s", m.TableTemplate, PlaceholderTable)
+	}
+	rest := strings.NewReplacer(PlaceholderSchema, "", PlaceholderTable, "").Replace(m.TableTemplate)
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("table template %q has an unknown placeholder, expected %s or %s", m.TableTemplate, PlaceholderSchema, PlaceholderTable)
+	}
+	return nil
+}
+
+// tableName fills the template with the names of a source table and applies the case
+func (m MappingConfiguration) tableName(schema string, table string) string {
+	return m.applyCase(strings.NewReplacer(PlaceholderSchema, schema, PlaceholderTable, table).Replace(m.TableTemplate))
+}
+
+// applyCase returns the name in the configured case
+func (m MappingConfiguration) applyCase(name string) string {
+	switch m.Case {
+	case NameCaseLower:
+		return strings.ToLower(name)
+	case NameCaseUpper:
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+// GetTarget returns the Doris database and table a source table is loaded into, both in the configured case.
+// The target_database and target_table of its table options win over the mapping and are used as written.
+func (c Configuration) GetTarget(database string, schema string, table string) TableTarget {
+	if mapped, ok := c.Mapping.Databases[schema]; ok {
+		database = mapped
+	}
+	target := TableTarget{Database: c.Mapping.applyCase(database), Table: c.Mapping.tableName(schema, table)}
+
+	options := c.GetTableOptions(schema, table)
+	if options.TargetDatabase != "" {
+		target.Database = options.TargetDatabase
+	}
+	if options.TargetTable != "" {
+		target.Table = options.TargetTable
+	}
+	return target
+}
@@ -0,0 +1,61 @@
+package doris
+
+import "testing"
+
+func TestGetTarget(t *testing.T) {
+	cases := []struct {
+		name    string
+		mapping MappingConfiguration
+		options []TableOptions
+		schema  string
+		table   string
+		want    TableTarget
+	}{
+		{
+			name:    "defaults",
+			mapping: MappingConfiguration{},
+			schema:  "Sales",
+			table:   "Orders",
+			want:    TableTarget{Database: "Warehouse", Table: "Orders"},
+		},
+		{
+			name:    "lower case database of the connection",
+			mapping: MappingConfiguration{TableTemplate: "{schema}_{table}", Case: NameCaseLower},
+			schema:  "Sales",
+			table:   "Orders",
+			want:    TableTarget{Database: "warehouse", Table: "sales_orders"},
+		},
+		{
+			name:    "lower case mapped database",
+			mapping: MappingConfiguration{Databases: map[string]string{"Sales": "Sales"}, TableTemplate: "{schema}_{table}", Case: NameCaseLower},
+			schema:  "Sales",
+			table:   "Orders",
+			want:    TableTarget{Database: "sales", Table: "sales_orders"},
+		},
+		{
+			name:    "upper case mapped database",
+			mapping: MappingConfiguration{Databases: map[string]string{"sales": "dw_sales"}, Case: NameCaseUpper},
+			schema:  "sales",
+			table:   "orders",
+			want:    TableTarget{Database: "DW_SALES", Table: "ORDERS"},
+		},
+		{
+			name:    "table options are used as written",
+			mapping: MappingConfiguration{Case: NameCaseLower},
+			options: []TableOptions{{Schema: "Sales", Table: "Orders", TargetDatabase: "Archive", TargetTable: "Orders_2024"}},
+			schema:  "Sales",
+			table:   "Orders",
+			want:    TableTarget{Database: "Archive", Table: "Orders_2024"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mapping.SetDefaults()
+			configuration := Configuration{Mapping: tc.mapping, Tables: tc.options}
+			if got := configuration.GetTarget("Warehouse", tc.schema, tc.table); got != tc.want {
+				t.Errorf("GetTarget(%s, %s) = %v, want %v", tc.schema, tc.table, got, tc.want)
+			}
+		})
+	}
+}
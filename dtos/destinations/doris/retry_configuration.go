@@ -0,0 +1,27 @@
+package doris
+
+// RetryConfiguration holds the retry policy of failed Stream Loads
+type RetryConfiguration struct {
+	// MaxAttempts is the number of times a batch is sent, including the first attempt
+	MaxAttempts int `json:"max_attempts"`
+	// InitialBackoffMs is the delay before the first retry, doubled after every attempt
+	InitialBackoffMs int `json:"initial_backoff_ms"`
+	// MaxBackoffMs caps the delay between two attempts
+	MaxBackoffMs int `json:"max_backoff_ms"`
+}
+
+// SetDefaults sets default values for optional fields
+func (r *RetryConfiguration) SetDefaults() {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = 5
+	}
+	if r.InitialBackoffMs <= 0 {
+		r.InitialBackoffMs = 1000
+	}
+	if r.MaxBackoffMs <= 0 {
+		r.MaxBackoffMs = 30000
+	}
+	if r.MaxBackoffMs < r.InitialBackoffMs {
+		r.MaxBackoffMs = r.InitialBackoffMs
+	}
+}
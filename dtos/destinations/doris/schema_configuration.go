@@ -0,0 +1,18 @@
+package doris
+
+// SchemaConfiguration holds the settings of the tables created by the schema command
+type SchemaConfiguration struct {
+	// Buckets is the number of hash buckets of every table, AUTO when 0
+	Buckets int `json:"buckets"`
+	// Properties are added to the PROPERTIES clause of every table
+	Properties map[string]string `json:"properties"`
+}
+
+// SetDefaults sets default values for optional fields
+func (s *SchemaConfiguration) SetDefaults() {
+	if s.Properties == nil {
+		s.Properties = map[string]string{
+			"enable_unique_key_merge_on_write": "true",
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package doris
+
+// Stream Load statuses returned by Doris
+const (
+	StreamLoadStatusSuccess = "Success"
+	// StreamLoadStatusPublishTimeout means the transaction is committed and becomes visible shortly
+	StreamLoadStatusPublishTimeout = "Publish Timeout"
+	StreamLoadStatusLabelExists    = "Label Already Exists"
+	StreamLoadStatusFail           = "Fail"
+)
+
+// ExistingJobFinished is the ExistingJobStatus of a label whose load was committed
+const ExistingJobFinished = "FINISHED"
+
+// StreamLoadResult is the JSON body of a Stream Load response
+type StreamLoadResult struct {
+	TxnId  int64  `json:"TxnId"`
+	Label  string `json:"Label"`
+	Status string `json:"Status"`
+	// ExistingJobStatus is the state of the earlier load owning the label, RUNNING or FINISHED
+	ExistingJobStatus    string `json:"ExistingJobStatus"`
+	Message              string `json:"Message"`
+	NumberTotalRows      int64  `json:"NumberTotalRows"`
+	NumberLoadedRows     int64  `json:"NumberLoadedRows"`
+	NumberFilteredRows   int64  `json:"NumberFilteredRows"`
+	NumberUnselectedRows int64  `json:"NumberUnselectedRows"`
+	LoadBytes            int64  `json:"LoadBytes"`
+	LoadTimeMs           int64  `json:"LoadTimeMs"`
+	ErrorURL             string `json:"ErrorURL"`
+}
+
+// Succeeded reports whether the rows of the load are committed
+func (r StreamLoadResult) Succeeded() bool {
+	return r.Status == StreamLoadStatusSuccess || r.Status == StreamLoadStatusPublishTimeout
+}
@@ -0,0 +1,88 @@
+package doris
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Merge types of Stream Loads into Unique Key tables
+const (
+	MergeTypeAppend = "APPEND"
+	MergeTypeMerge  = "MERGE"
+	MergeTypeDelete = "DELETE"
+)
+
+// TableOptions holds the load settings of a single table
+type TableOptions struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// TargetDatabase and TargetTable override the mapped Doris database and table of the table
+	TargetDatabase string `json:"target_database"`
+	TargetTable    string `json:"target_table"`
+	// TwoPhaseCommit makes every load of the table visible at once when the table completes
+	TwoPhaseCommit bool `json:"two_phase_commit"`
+	// PartialColumns updates only the listed Columns of existing rows, the other columns keep their values
+	PartialColumns bool `json:"partial_columns"`
+	// Columns are the columns loaded, in order, all the columns of the source table when empty
+	Columns []string `json:"columns"`
+	// MergeType is APPEND, MERGE or DELETE, Delete is the condition marking the rows MERGE deletes
+	MergeType string `json:"merge_type"`
+	Delete    string `json:"delete"`
+	// SequenceCol resolves rows with the same key by the highest value of this column instead of the last load
+	SequenceCol string `json:"sequence_col"`
+	// Rename maps source columns to the Doris columns they are loaded into, the other columns keep their name
+	Rename map[string]string `json:"rename"`
+}
+
+// Validate checks the combination of options
+func (t TableOptions) Validate() error {
+	switch strings.ToUpper(t.MergeType) {
+	case "", MergeTypeAppend, MergeTypeDelete:
+		if t.Delete != "" {
+			return fmt.Errorf("table %s.%s: delete condition requires merge_type %s", t.Schema, t.Table, MergeTypeMerge)
+		}
+	case MergeTypeMerge:
+		if t.Delete == "" {
+			return fmt.Errorf("table %s.%s: merge_type %s requires a delete condition", t.Schema, t.Table, MergeTypeMerge)
+		}
+	default:
+		return fmt.Errorf("table %s.%s: unknown merge_type %q, expected %s, %s or %s", t.Schema, t.Table, t.MergeType, MergeTypeAppend, MergeTypeMerge, MergeTypeDelete)
+	}
+
+	if t.PartialColumns && len(t.Columns) == 0 {
+		return fmt.Errorf("table %s.%s: partial_columns requires the columns to update", t.Schema, t.Table)
+	}
+	if t.SequenceCol != "" && len(t.Columns) > 0 && !slices.Contains(t.Columns, t.SequenceCol) {
+		return fmt.Errorf("table %s.%s: sequence_col %s must be one of the loaded columns", t.Schema, t.Table, t.SequenceCol)
+	}
+	for column, name := range t.Rename {
+		if name == "" || column == DeleteSignColumn || name == DeleteSignColumn {
+			return fmt.Errorf("table %s.%s: invalid rename of column %q to %q", t.Schema, t.Table, column, name)
+		}
+	}
+	return nil
+}
+
+// ColumnName returns the Doris column a source column is loaded into
+func (t TableOptions) ColumnName(column string) string {
+	if name, ok := t.Rename[column]; ok {
+		return name
+	}
+	return column
+}
+
+// GetTableOptions returns the options of a table, the zero options when it has none
+func (c Configuration) GetTableOptions(schema string, table string) TableOptions {
+	for _, options := range c.Tables {
+		if options.Schema == schema && options.Table == table {
+			return options
+		}
+	}
+	return TableOptions{Schema: schema, Table: table}
+}
+
+// UsesTwoPhaseCommit reports whether the loads of a table are committed together once it completes
+func (c Configuration) UsesTwoPhaseCommit(schema string, table string) bool {
+	return c.TwoPhaseCommit || c.GetTableOptions(schema, table).TwoPhaseCommit
+}
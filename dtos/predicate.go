@@ -0,0 +1,8 @@
+package dtos
+
+// Predicate is an SQL condition ANDed into every query that reads a table.
+// Placeholders are numbered from $1 relative to Args and renumbered when the query is built.
+type Predicate struct {
+	Clause string `json:"clause"`
+	Args   []any  `json:"args"`
+}
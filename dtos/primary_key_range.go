@@ -1,7 +1,9 @@
 package dtos
 
 type PrimaryKeyRange struct {
+	Seq           uint64            `json:"seq"`
 	Type          string            `json:"type"`
 	IdRange       [2]any            `json:"id_range"`
 	MultiKeyRange [2]map[string]any `json:"multi_key_range"`
+	Keys          uint64            `json:"keys"`
 }
@@ -0,0 +1,77 @@
+package dtos
+
+import "sync"
+
+// RangeTracker follows the planned key ranges of a table until every record of each range is loaded.
+// Ranges complete out of order, the tracker only advances over the contiguous prefix of completed ranges.
+type RangeTracker struct {
+	mu        sync.Mutex
+	ranges    map[uint64]*trackedRange
+	nextSeq   uint64
+	committed uint64
+}
+
+type trackedRange struct {
+	keyRange PrimaryKeyRange
+	fetched  bool
+	records  uint64
+	loaded   uint64
+}
+
+func NewRangeTracker() *RangeTracker {
+	return &RangeTracker{
+		ranges: make(map[uint64]*trackedRange),
+	}
+}
+
+// Register assigns the next sequence number to a key range and starts tracking it
+func (t *RangeTracker) Register(keyRange PrimaryKeyRange) PrimaryKeyRange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextSeq++
+	keyRange.Seq = t.nextSeq
+	t.ranges[keyRange.Seq] = &trackedRange{keyRange: keyRange}
+	return keyRange
+}
+
+// SetRecordCount records how many rows were read for a key range
+func (t *RangeTracker) SetRecordCount(seq uint64, count uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r, ok := t.ranges[seq]; ok {
+		r.fetched = true
+		r.records = count
+	}
+}
+
+// Acknowledge marks rows of the given ranges as loaded into the destination
+func (t *RangeTracker) Acknowledge(loadedBySeq map[uint64]uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for seq, count := range loadedBySeq {
+		if r, ok := t.ranges[seq]; ok {
+			r.loaded += count
+		}
+	}
+}
+
+// Advance returns the highest range of the contiguous prefix of fully loaded ranges.
+// ok is false when no new range completed since the previous call.
+func (t *RangeTracker) Advance() (keyRange PrimaryKeyRange, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for {
+		r, exists := t.ranges[t.committed+1]
+		if !exists || !r.fetched || r.loaded < r.records {
+			return keyRange, ok
+		}
+
+		t.committed++
+		keyRange, ok = r.keyRange, true
+		delete(t.ranges, t.committed)
+	}
+}
@@ -0,0 +1,60 @@
+package dtos
+
+import "testing"
+
+func TestRangeTrackerAdvance(t *testing.T) {
+	tracker := NewRangeTracker()
+	var ranges []PrimaryKeyRange
+	for i := 1; i <= 4; i++ {
+		ranges = append(ranges, tracker.Register(PrimaryKeyRange{Type: "id_range", IdRange: [2]any{i * 10, i*10 + 9}}))
+	}
+	for i, keyRange := range ranges {
+		if keyRange.Seq != uint64(i+1) {
+			t.Fatalf("range %d registered with seq %d", i, keyRange.Seq)
+		}
+	}
+
+	advance := func(step string, wantOk bool, wantSeq uint64) {
+		t.Helper()
+		keyRange, ok := tracker.Advance()
+		if ok != wantOk || (ok && keyRange.Seq != wantSeq) {
+			t.Fatalf("%s: Advance() = seq %d, %v, want seq %d, %v", step, keyRange.Seq, ok, wantSeq, wantOk)
+		}
+	}
+
+	advance("nothing fetched", false, 0)
+
+	// Later ranges completing first do not move the checkpoint past an incomplete one
+	tracker.SetRecordCount(2, 3)
+	tracker.SetRecordCount(3, 0)
+	tracker.Acknowledge(map[uint64]uint64{2: 3})
+	advance("range 1 not fetched", false, 0)
+
+	tracker.SetRecordCount(1, 5)
+	tracker.Acknowledge(map[uint64]uint64{1: 4})
+	advance("range 1 partially loaded", false, 0)
+
+	// Completing range 1 commits the whole contiguous prefix, the empty range 3 included
+	tracker.Acknowledge(map[uint64]uint64{1: 1})
+	advance("ranges 1 to 3 loaded", true, 3)
+	advance("no new range", false, 0)
+
+	tracker.SetRecordCount(4, 2)
+	tracker.Acknowledge(map[uint64]uint64{4: 1})
+	advance("range 4 partially loaded", false, 0)
+	tracker.Acknowledge(map[uint64]uint64{4: 1, 9: 5})
+	advance("range 4 loaded", true, 4)
+}
+
+func TestRangeTrackerUnfetchedRangeBlocks(t *testing.T) {
+	tracker := NewRangeTracker()
+	tracker.Register(PrimaryKeyRange{Type: "ctid_range", IdRange: [2]any{int64(0), int64(8)}})
+	tracker.Register(PrimaryKeyRange{Type: "ctid_range", IdRange: [2]any{int64(8), nil}})
+
+	// A range that failed to read is never fetched, the ranges after it never commit
+	tracker.SetRecordCount(2, 1)
+	tracker.Acknowledge(map[uint64]uint64{2: 1})
+	if keyRange, ok := tracker.Advance(); ok {
+		t.Errorf("Advance() committed seq %d past an unfetched range", keyRange.Seq)
+	}
+}
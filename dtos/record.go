@@ -0,0 +1,7 @@
+package dtos
+
+// Record is a single source row tagged with the sequence number of the key range it was read from
+type Record struct {
+	RangeSeq uint64
+	Values   map[string]any
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import "time"
+
+// CdcConfiguration holds the settings of the logical replication stream used by the cdc command
+type CdcConfiguration struct {
+	SlotName              string `json:"slot_name"`
+	Publication           string `json:"publication"`
+	BatchSize             int    `json:"batch_size"`
+	FlushIntervalMs       int    `json:"flush_interval_ms"`
+	StatusIntervalSeconds int    `json:"status_interval_seconds"`
+}
+
+// SetDefaults sets default values for optional fields
+func (c *CdcConfiguration) SetDefaults() {
+	if c.SlotName == "" {
+		c.SlotName = "migration_tool_go"
+	}
+	if c.Publication == "" {
+		c.Publication = "migration_tool_go"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10000
+	}
+	if c.FlushIntervalMs <= 0 {
+		c.FlushIntervalMs = 1000
+	}
+	if c.StatusIntervalSeconds <= 0 {
+		c.StatusIntervalSeconds = 10
+	}
+}
+
+// GetFlushInterval returns the maximum time changes are buffered before being flushed
+func (c *CdcConfiguration) GetFlushInterval() time.Duration {
+	return time.Duration(c.FlushIntervalMs) * time.Millisecond
+}
+
+// GetStatusInterval returns the interval between standby status updates sent to the server
+func (c *CdcConfiguration) GetStatusInterval() time.Duration {
+	return time.Duration(c.StatusIntervalSeconds) * time.Second
+}
@@ -1,10 +1,20 @@
 package postgres
 
+import "migration-tool-go/dtos"
+
 type Configuration struct {
 	Schemas               []string                `json:"schemas"`
 	ExcludedSchemas       []string                `json:"excluded_schemas"`
 	ExcludeTableRegexList []ExcludeTableRegexList `json:"exclude_table_regex_list"`
 	ExcludeTablesList     []ExcludeTablesList     `json:"exclude_tables_list"`
+	IncludeTableRegexList []IncludeTableRegexList `json:"include_table_regex_list"`
+	IncludeTablesList     []IncludeTablesList     `json:"include_tables_list"`
+	Incremental           []IncrementalTable      `json:"incremental"`
+	RowFilter             dtos.Predicate          `json:"row_filter"`
+	RowFilters            []TableRowFilter        `json:"row_filters"`
+	Projections           []TableProjection       `json:"projections"`
+	Cdc                   CdcConfiguration        `json:"cdc"`
+	Conversion            ConversionConfiguration `json:"conversion"`
 	Pool                  uint                    `json:"pool"`
 }
 
@@ -17,3 +27,70 @@ type ExcludeTablesList struct {
 	Schema string   `json:"schema"`
 	Tables []string `json:"tables"`
 }
+
+// IncludeTableRegexList restricts a schema to the tables matching one of the regular expressions
+type IncludeTableRegexList struct {
+	Schema string   `json:"schema"`
+	Regex  []string `json:"regex"`
+}
+
+// IncludeTablesList restricts a schema to the listed tables, entries may be glob patterns
+type IncludeTablesList struct {
+	Schema string   `json:"schema"`
+	Tables []string `json:"tables"`
+}
+
+// IncrementalTable names the monotonically increasing column used as the watermark of a table
+type IncrementalTable struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// GetIncrementalColumn returns the watermark column of a table, ok is false for full loads
+func (c Configuration) GetIncrementalColumn(schema string, table string) (string, bool) {
+	for _, incremental := range c.Incremental {
+		if incremental.Schema == schema && incremental.Table == table {
+			return incremental.Column, true
+		}
+	}
+	return "", false
+}
+
+// TableRowFilter is an SQL condition restricting the extracted rows of a table. Placeholders are numbered
+// from $1 and bound to Args, an empty clause disables the global row filter for the table.
+type TableRowFilter struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Clause string `json:"clause"`
+	Args   []any  `json:"args"`
+}
+
+// GetRowFilter returns the row filter of a table, falling back to the global one, ok is false when every row is extracted
+func (c Configuration) GetRowFilter(schema string, table string) (dtos.Predicate, bool) {
+	for _, filter := range c.RowFilters {
+		if filter.Schema == schema && filter.Table == table {
+			return dtos.Predicate{Clause: filter.Clause, Args: filter.Args}, filter.Clause != ""
+		}
+	}
+	return c.RowFilter, c.RowFilter.Clause != ""
+}
+
+// TableProjection restricts the columns extracted from a table, the key columns are always extracted
+type TableProjection struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// IncludeColumns are the only columns extracted, ExcludeColumns are never extracted
+	IncludeColumns []string `json:"include_columns"`
+	ExcludeColumns []string `json:"exclude_columns"`
+}
+
+// GetProjection returns the projection of a table, ok is false when every column is extracted
+func (c Configuration) GetProjection(schema string, table string) (TableProjection, bool) {
+	for _, projection := range c.Projections {
+		if projection.Schema == schema && projection.Table == table {
+			return projection, true
+		}
+	}
+	return TableProjection{}, false
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+)
+
+// Encodings of bytea values
+const (
+	ByteaEncodingBase64 = "base64"
+	ByteaEncodingHex    = "hex"
+)
+
+// ConversionConfiguration holds how extracted values are converted before they are loaded
+type ConversionConfiguration struct {
+	// Timezone is the IANA time zone timestamptz values are loaded in, UTC by default
+	Timezone string `json:"timezone"`
+	// ByteaEncoding is base64 or hex, base64 by default
+	ByteaEncoding string `json:"bytea_encoding"`
+}
+
+// SetDefaults sets default values for optional fields
+func (c *ConversionConfiguration) SetDefaults() {
+	if c.Timezone == "" {
+		c.Timezone = "UTC"
+	}
+	if c.ByteaEncoding == "" {
+		c.ByteaEncoding = ByteaEncodingBase64
+	}
+}
+
+// Validate checks the time zone and the bytea encoding
+func (c ConversionConfiguration) Validate() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid conversion timezone %q: %w", c.Timezone, err)
+	}
+	switch c.ByteaEncoding {
+	case ByteaEncodingBase64, ByteaEncodingHex:
+	default:
+		return fmt.Errorf("unknown bytea encoding %q, expected %s or %s", c.ByteaEncoding, ByteaEncodingBase64, ByteaEncodingHex)
+	}
+	return nil
+}
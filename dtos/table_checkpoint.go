@@ -0,0 +1,45 @@
+package dtos
+
+import "time"
+
+const (
+	TableStatusInProgress = "in_progress"
+	TableStatusCompleted  = "completed"
+)
+
+// TableCheckpoint is the persisted progress of a single table
+type TableCheckpoint struct {
+	Schema  string         `json:"schema"`
+	Table   string         `json:"table"`
+	Status  string         `json:"status"`
+	LastId  any            `json:"last_id,omitempty"`
+	LastIds map[string]any `json:"last_ids,omitempty"`
+	// KeyStrategy is the strategy LastId and LastIds belong to
+	KeyStrategy string `json:"key_strategy,omitempty"`
+	// Watermark is the incremental column value up to which the table has been synced
+	Watermark any `json:"watermark,omitempty"`
+	// PendingWatermark is the upper bound of the window being synced, promoted to Watermark on completion
+	PendingWatermark any       `json:"pending_watermark,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// HasLastKey reports whether the checkpoint carries a key to resume from
+func (c TableCheckpoint) HasLastKey() bool {
+	return c.LastId != nil || len(c.LastIds) > 0
+}
+
+// ReplicationCheckpoint is the persisted position of a logical replication slot
+type ReplicationCheckpoint struct {
+	SlotName string `json:"slot_name"`
+	// ConfirmedLSN is the end LSN of the last transaction loaded into the destination
+	ConfirmedLSN string    `json:"confirmed_lsn"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// MigrationState is the content of the state file
+type MigrationState struct {
+	// RunId prefixes the Stream Load labels, a resumed run keeps it so its labels match the loads of the interrupted run
+	RunId       string                            `json:"run_id,omitempty"`
+	Tables      map[string]*TableCheckpoint       `json:"tables"`
+	Replication map[string]*ReplicationCheckpoint `json:"replication,omitempty"`
+}
@@ -1,10 +1,22 @@
 package dtos
 
+// Key strategies splitting a table into key ranges, in order of preference
+const (
+	KeyStrategyPrimaryKey  = "primary_key"
+	KeyStrategyUniqueIndex = "unique_index"
+	KeyStrategyCtid        = "ctid"
+)
+
 type TableInfo struct {
 	TableSchema string       `json:"table_schema"`
 	TableName   string       `json:"table_name"`
 	Columns     []ColumnInfo `json:"columns"`
+	// PrimaryKeys are the key columns the table is paginated by, the columns of KeyIndex for the unique_index strategy
 	PrimaryKeys []PrimaryKey `json:"primary_keys"`
+	Predicates  []Predicate  `json:"predicates,omitempty"`
+	KeyStrategy string       `json:"key_strategy"`
+	// KeyIndex is the NOT NULL unique index providing the key columns of the unique_index strategy
+	KeyIndex string `json:"key_index,omitempty"`
 }
 
 type PrimaryKey struct {
@@ -7,9 +7,13 @@ import (
 type TableInfoChan struct {
 	TableInfo          TableInfo
 	PrimaryKeyRange    chan PrimaryKeyRange
-	RecordsChan        chan map[string]any
+	RecordsChan        chan Record
+	Ranges             *RangeTracker
+	Checkpoint         *TableCheckpoint
 	totalUuidsRead     uint64
 	totalRecordsRead   uint64
+	failedRanges       uint64
+	failedKeys         uint64
 	ReadingIdsDone     atomic.Value
 	ReadingRecordsDone atomic.Value
 }
@@ -18,7 +22,8 @@ func NewTableInfoChan(tableInfo TableInfo, primaryKeyRangeSize int, recordsChanS
 	tableInfoChan := &TableInfoChan{
 		TableInfo:          tableInfo,
 		PrimaryKeyRange:    make(chan PrimaryKeyRange, primaryKeyRangeSize),
-		RecordsChan:        make(chan map[string]any, recordsChanSize),
+		RecordsChan:        make(chan Record, recordsChanSize),
+		Ranges:             NewRangeTracker(),
 		totalUuidsRead:     0,
 		totalRecordsRead:   0,
 	}
@@ -42,3 +47,17 @@ func (t *TableInfoChan) GetTotalUuidsRead() uint64 {
 func (t *TableInfoChan) GetTotalRecordsRead() uint64 {
 	return atomic.LoadUint64(&t.totalRecordsRead)
 }
+
+// IncrementFailedRanges counts a key range whose records could not be read, its planned keys are settled as failed
+func (t *TableInfoChan) IncrementFailedRanges(keys uint64) {
+	atomic.AddUint64(&t.failedRanges, 1)
+	atomic.AddUint64(&t.failedKeys, keys)
+}
+
+func (t *TableInfoChan) GetFailedRanges() uint64 {
+	return atomic.LoadUint64(&t.failedRanges)
+}
+
+func (t *TableInfoChan) GetFailedKeys() uint64 {
+	return atomic.LoadUint64(&t.failedKeys)
+}
@@ -0,0 +1,21 @@
+package dtos
+
+// TablePlan is the dry run estimate of the migration of a single table
+type TablePlan struct {
+	Schema      string   `json:"schema"`
+	Table       string   `json:"table"`
+	TargetTable string   `json:"target_table"`
+	KeyStrategy string   `json:"key_strategy"`
+	KeyColumns  []string `json:"key_columns,omitempty"`
+	KeyIndex    string   `json:"key_index,omitempty"`
+	// IncrementalColumn is the watermark column of incremental tables, the estimates cover the whole table
+	IncrementalColumn string `json:"incremental_column,omitempty"`
+	// RowFilter is the condition restricting the extracted rows, the estimates cover the whole table
+	RowFilter string `json:"row_filter,omitempty"`
+	// Analyzed is false when the table has no statistics, its row based estimates are then zero
+	Analyzed      bool  `json:"analyzed"`
+	EstimatedRows int64 `json:"estimated_rows"`
+	TotalBytes    int64 `json:"total_bytes"`
+	KeyRanges     int64 `json:"key_ranges"`
+	StreamLoads   int64 `json:"stream_loads"`
+}
@@ -0,0 +1,21 @@
+package dtos
+
+import "time"
+
+// TransformAudit summarizes the column transforms applied during a run
+type TransformAudit struct {
+	Command     string              `json:"command"`
+	StartedAt   time.Time           `json:"started_at"`
+	CompletedAt time.Time           `json:"completed_at"`
+	Columns     []TransformedColumn `json:"columns"`
+}
+
+// TransformedColumn is a transformed or derived column and the number of records it was transformed in.
+// The entry of the drop_when rule has no column and counts the dropped records.
+type TransformedColumn struct {
+	Schema  string `json:"schema"`
+	Table   string `json:"table"`
+	Column  string `json:"column,omitempty"`
+	Rule    string `json:"rule"`
+	Records uint64 `json:"records"`
+}
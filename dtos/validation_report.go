@@ -0,0 +1,27 @@
+package dtos
+
+// TableValidation is the validation result of a single table
+type TableValidation struct {
+	Schema           string `json:"schema"`
+	Table            string `json:"table"`
+	TargetTable      string `json:"target_table"`
+	KeyStrategy      string `json:"key_strategy"`
+	SourceCount      int64  `json:"source_count"`
+	DestinationCount int64  `json:"destination_count"`
+	// ChecksumColumns are the columns compared per key range, empty when only counts were compared
+	ChecksumColumns  []string        `json:"checksum_columns,omitempty"`
+	RangesChecked    int             `json:"ranges_checked"`
+	MismatchedRanges []RangeMismatch `json:"mismatched_ranges,omitempty"`
+	Passed           bool            `json:"passed"`
+	Error            string          `json:"error,omitempty"`
+}
+
+// RangeMismatch is a key range whose rows differ between the source and the destination
+type RangeMismatch struct {
+	Start               any    `json:"start"`
+	End                 any    `json:"end"`
+	SourceRows          int    `json:"source_rows"`
+	DestinationRows     int    `json:"destination_rows"`
+	SourceChecksum      string `json:"source_checksum"`
+	DestinationChecksum string `json:"destination_checksum"`
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"migration-tool-go/config"
+	"migration-tool-go/dtos"
 	"migration-tool-go/logger"
 	"migration-tool-go/services"
 	"os"
@@ -12,6 +14,13 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/samber/lo"
+)
+
+// Command specific flags
+var (
+	execute = flag.Bool("execute", false, "schema: execute the generated DDL through the Doris FE instead of printing it")
+	format  = flag.String("format", "text", "plan: output format, text or json")
 )
 
 func main() {
@@ -27,10 +36,23 @@ func main() {
 
 	// Define flags
 	configPath := flag.String("config_path", "config/config.json", "Path of the config json")
+	resume := flag.Bool("resume", false, "Resume from the checkpoints of the state file, completed tables are skipped")
 
 	// Parse the flags
 	flag.Parse()
 
+	// The command is the first positional argument, migrate by default
+	command := flag.Arg(0)
+	if command == "" {
+		command = "migrate"
+	}
+	if _, ok := commands[command]; !ok {
+		logger.Sugar.Fatalf("Unknown command %q, expected one of: migrate, cdc, snapshot-cdc, schema, validate, plan", command)
+	}
+	if *resume && command == "snapshot-cdc" {
+		logger.Sugar.Fatal("The snapshot-cdc command cannot resume, its snapshot only lives as long as the run")
+	}
+
 	// Create a cancellable context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -46,7 +68,7 @@ func main() {
 
 	// Initialize configuration
 	logger.Sugar.Infow("Initializing configuration from", "configPath", *configPath)
-	config.InitializeConfig(*configPath)
+	config.InitializeConfig(*configPath, services.SourceDecoder, services.SinkDecoder)
 
 	// Initialize stats service from config
 	logger.Sugar.Info("Initializing stats service")
@@ -58,22 +80,195 @@ func main() {
 	// Ensure stats service is stopped when the application exits
 	defer services.StatsService.Stop()
 
+	// Initialize checkpoints, resuming from the state file if requested
+	if err := services.NewCheckpointService(config.TrackingConfig, *resume); err != nil {
+		logger.Sugar.Fatalf("Failed to initialize checkpoint service: %v", err)
+	}
+
+	// Initialize the column transforms applied before loading
+	if err := services.NewTransformService(config.TransformConfig); err != nil {
+		logger.Sugar.Fatalf("Failed to initialize transform service: %v", err)
+	}
+
 	// Initialize services
-	logger.Sugar.Info("Initializing PostgreSQL migration service")
-	services.NewPostgresMigration(config.SourceConfig, config.WorkerConfig)
+	logger.Sugar.Infof("Initializing %s source", config.SourceConfig.Type)
+	source, err := services.NewSource(config.SourceConfig, config.WorkerConfig)
+	if err != nil {
+		logger.Sugar.Fatalf("Failed to initialize source: %v", err)
+	}
+
+	logger.Sugar.Infof("Initializing %s sink", config.DestinationConfig.Type)
+	sink, err := services.NewSink(config.DestinationConfig)
+	if err != nil {
+		logger.Sugar.Fatalf("Failed to initialize sink: %v", err)
+	}
+	defer sink.Close()
 
-	logger.Sugar.Info("Initializing Doris sync service")
-	services.NewDorisSync(config.DestinationConfig)
+	err = commands[command](ctx, source, sink)
 
-	logger.Sugar.Info("Initializing migration runner")
-	services.NewMigrationRunner(config.WorkerConfig)
+	// Commands loading data audit the columns they transformed, also when they fail
+	if loadingCommands[command] {
+		if auditErr := services.TransformService.WriteAudit(command); auditErr != nil {
+			logger.Sugar.Errorf("Failed to write transform audit: %v", auditErr)
+		}
+	}
 
-	logger.Sugar.Info("Starting migration process")
-	if err := services.MigrationRunner.Run(ctx); err != nil {
-		logger.Sugar.Errorf("Migration failed: %v", err)
+	if err != nil {
+		logger.Sugar.Errorf("Command %s failed: %v", command, err)
+		// os.Exit skips the deferred Close, which aborts the loads left uncommitted
+		if err := sink.Close(); err != nil {
+			logger.Sugar.Errorf("Failed to close sink: %v", err)
+		}
 		os.Exit(1)
 	}
 
 	// Report completion
-	logger.Sugar.Infof("Migration completed successfully in %s", time.Since(startTime))
+	logger.Sugar.Infof("Command %s completed successfully in %s", command, time.Since(startTime))
+}
+
+// commands maps every command to the function running it
+var commands = map[string]func(ctx context.Context, source services.Source, sink services.Sink) error{
+	"migrate":      runMigration,
+	"cdc":          runCdc,
+	"snapshot-cdc": runSnapshotCdc,
+	"schema":       runSchema,
+	"validate":     runValidate,
+	"plan":         runPlan,
+}
+
+// loadingCommands are the commands writing records to the sink
+var loadingCommands = map[string]bool{"migrate": true, "cdc": true, "snapshot-cdc": true}
+
+// runMigration copies every selected table from the source to the sink, validating each loaded table
+// when validation after_migration is set
+func runMigration(ctx context.Context, source services.Source, sink services.Sink) error {
+	logger.Sugar.Info("Initializing migration runner")
+	services.NewMigrationRunner(source, sink, config.WorkerConfig)
+
+	if !config.ValidationConfig.AfterMigration {
+		logger.Sugar.Info("Starting migration process")
+		return services.MigrationRunner.Run(ctx)
+	}
+
+	validation, err := services.NewValidationService(ctx, source, sink, config.ValidationConfig, config.WorkerConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize validation: %w", err)
+	}
+	defer validation.Close()
+
+	var results []dtos.TableValidation
+	services.MigrationRunner.OnTableCompleted(func(ctx context.Context, table dtos.TableInfo) {
+		results = append(results, validation.ValidateTable(ctx, table))
+	})
+
+	logger.Sugar.Info("Starting migration process with validation")
+	if err := services.MigrationRunner.Run(ctx); err != nil {
+		return err
+	}
+	return reportValidation(validation.WriteReport, results)
+}
+
+// runCdc streams the changes of the source tables into the sink until interrupted
+func runCdc(ctx context.Context, source services.Source, sink services.Sink) error {
+	logger.Sugar.Info("Initializing change data capture")
+	cdc, err := services.NewCdcService(source, sink)
+	if err != nil {
+		return fmt.Errorf("failed to initialize change data capture: %w", err)
+	}
+
+	logger.Sugar.Info("Starting change data capture")
+	return cdc.Run(ctx)
+}
+
+// runSnapshotCdc copies every selected table under the snapshot of a new replication slot and then streams
+// the changes committed after it, for a zero downtime cutover
+func runSnapshotCdc(ctx context.Context, source services.Source, sink services.Sink) error {
+	logger.Sugar.Info("Initializing change data capture")
+	cdc, err := services.NewCdcService(source, sink)
+	if err != nil {
+		return fmt.Errorf("failed to initialize change data capture: %w", err)
+	}
+
+	logger.Sugar.Info("Starting snapshot copy followed by change data capture")
+	return cdc.RunWithSnapshot(ctx, func(ctx context.Context) error {
+		if err := runMigration(ctx, source, sink); err != nil {
+			return err
+		}
+		// Streaming on top of a partial copy would leave rows missing for good
+		if failed := services.MigrationRunner.FailedTables(); failed > 0 {
+			return fmt.Errorf("%d tables had records that failed to load", failed)
+		}
+		return nil
+	})
+}
+
+// runSchema prints the Doris DDL of every selected table, or executes it with -execute
+func runSchema(ctx context.Context, source services.Source, sink services.Sink) error {
+	schema, err := services.NewSchemaService(source, sink)
+	if err != nil {
+		return fmt.Errorf("failed to initialize schema generation: %w", err)
+	}
+
+	statements, err := schema.Generate(ctx)
+	if !*execute {
+		for _, statement := range statements {
+			fmt.Printf("%s\n\n", statement)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Sugar.Infof("Executing %d statements on the Doris FE", len(statements))
+	return schema.Apply(ctx, statements)
+}
+
+// runValidate compares every selected table with its copy in the destination
+func runValidate(ctx context.Context, source services.Source, sink services.Sink) error {
+	validation, err := services.NewValidationService(ctx, source, sink, config.ValidationConfig, config.WorkerConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize validation: %w", err)
+	}
+	defer validation.Close()
+
+	logger.Sugar.Info("Starting validation")
+	results, err := validation.ValidateTables(ctx)
+	if err != nil {
+		return err
+	}
+	return reportValidation(validation.WriteReport, results)
+}
+
+// reportValidation writes the validation report and fails when any table did not pass
+func reportValidation(writeReport func([]dtos.TableValidation) error, results []dtos.TableValidation) error {
+	if err := writeReport(results); err != nil {
+		return err
+	}
+
+	failed := lo.CountBy(results, func(result dtos.TableValidation) bool { return !result.Passed })
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tables failed validation", failed, len(results))
+	}
+
+	logger.Sugar.Infof("All %d tables passed validation", len(results))
+	return nil
+}
+
+// runPlan prints the size estimates and key strategy of every selected table without loading any data
+func runPlan(ctx context.Context, source services.Source, sink services.Sink) error {
+	plan, err := services.NewPlanService(source, sink, config.WorkerConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize planning: %w", err)
+	}
+
+	// Mapping collisions are reported once the plan of every table is written
+	plans, err := plan.Plan(ctx)
+	if plans == nil && err != nil {
+		return err
+	}
+	if writeErr := plan.Write(os.Stdout, plans, *format); writeErr != nil {
+		return writeErr
+	}
+	return err
 }
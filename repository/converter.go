@@ -0,0 +1,587 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/sources/postgres"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// datetimeLayout is how timestamps are loaded, Doris DATETIME reads it
+	datetimeLayout = "2006-01-02 15:04:05.999999"
+	// TimestamptzKeyLayout renders timestamptz key values with their offset, so they compare exactly when bound again
+	TimestamptzKeyLayout = "2006-01-02 15:04:05.999999-07:00"
+)
+
+// Infinite dates and timestamps are loaded as the bounds of the Doris DATE and DATETIME ranges
+const (
+	infinityDate             = "9999-12-31"
+	negativeInfinityDate     = "0000-01-01"
+	infinityDatetime         = "9999-12-31 23:59:59.999999"
+	negativeInfinityDatetime = "0000-01-01 00:00:00"
+)
+
+// valueConverter converts a non nil value decoded by pgx into the value loaded into the destination
+type valueConverter func(c *Converters, value any) any
+
+// converters are keyed by udt_name. Types without a converter, like text, integers and enums, are loaded as decoded.
+var converters = map[string]valueConverter{
+	"uuid":        convertUuid,
+	"json":        convertJson,
+	"jsonb":       convertJson,
+	"numeric":     convertNumeric,
+	"date":        convertDate,
+	"timestamp":   convertTimestamp,
+	"timestamptz": convertTimestamptz,
+	"time":        convertTime,
+	"interval":    convertInterval,
+	"inet":        convertInet,
+	"cidr":        convertCidr,
+	"macaddr":     convertMacaddr,
+	"macaddr8":    convertMacaddr,
+	"bytea":       convertBytea,
+	"bit":         convertBits,
+	"varbit":      convertBits,
+	"hstore":      convertHstore,
+}
+
+// rangeBounds maps the built in range and multirange types to the type of their bounds
+var rangeBounds = map[string]string{
+	"int4range":      "int4",
+	"int8range":      "int8",
+	"numrange":       "numeric",
+	"daterange":      "date",
+	"tsrange":        "timestamp",
+	"tstzrange":      "timestamptz",
+	"int4multirange": "int4",
+	"int8multirange": "int8",
+	"nummultirange":  "numeric",
+	"datemultirange": "date",
+	"tsmultirange":   "timestamp",
+	"tstzmultirange": "timestamptz",
+}
+
+// Converters turn the values read from PostgreSQL into the values loaded into the destination.
+// Array columns convert each of their elements with the converter of the element type.
+type Converters struct {
+	location          *time.Location
+	byteaEncoding     string
+	timestamptzLayout string
+	typeMap           *pgtype.Map
+}
+
+// NewConverters creates the converters of the configured time zone and bytea encoding
+func NewConverters(config postgres.ConversionConfiguration) (*Converters, error) {
+	config.SetDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Validate already loaded the location
+	location, _ := time.LoadLocation(config.Timezone)
+	return &Converters{
+		location:          location,
+		byteaEncoding:     config.ByteaEncoding,
+		timestamptzLayout: datetimeLayout,
+		typeMap:           pgtype.NewMap(),
+	}, nil
+}
+
+// forKeys returns the converters of key values, which are bound again as query parameters
+func (c *Converters) forKeys() *Converters {
+	keys := *c
+	keys.timestamptzLayout = TimestamptzKeyLayout
+	return &keys
+}
+
+// Convert converts a value of a column, nil stays nil
+func (c *Converters) Convert(column dtos.ColumnInfo, value any) any {
+	if pointer, ok := value.(*any); ok {
+		value = *pointer
+	}
+	if value == nil {
+		return nil
+	}
+	return c.convert(column.DataType, value)
+}
+
+func (c *Converters) convert(dataType string, value any) any {
+	if elementType, ok := strings.CutPrefix(dataType, "_"); ok {
+		return c.convertArray(elementType, value)
+	}
+
+	converter, ok := converters[dataType]
+	if !ok {
+		boundType, ok := rangeBounds[dataType]
+		if !ok {
+			return value
+		}
+		converter = func(c *Converters, value any) any { return c.convertRange(boundType, value) }
+	}
+
+	// pgx decodes the types it does not know as text, like domains sent by logical replication.
+	// JSON documents can be strings themselves.
+	if text, ok := value.(string); ok && dataType != "json" && dataType != "jsonb" {
+		value = c.decodeText(dataType, text)
+	}
+	return converter(c, value)
+}
+
+// decodeText decodes the text form of a type pgx knows, other text is returned as is
+func (c *Converters) decodeText(dataType string, text string) any {
+	pgType, ok := c.typeMap.TypeForName(dataType)
+	if !ok {
+		return text
+	}
+	value, err := pgType.Codec.DecodeValue(c.typeMap, pgType.OID, pgtype.TextFormatCode, []byte(text))
+	if err != nil || value == nil {
+		return text
+	}
+	return value
+}
+
+// convertArray converts the elements of an array, arrays of types pgx does not know arrive in their text form
+func (c *Converters) convertArray(elementType string, value any) any {
+	if text, ok := value.(string); ok {
+		elements, err := parseArray(text)
+		if err != nil {
+			return text
+		}
+		value = elements
+	}
+
+	elements, ok := value.([]any)
+	if !ok {
+		return value
+	}
+	converted := make([]any, len(elements))
+	for i, element := range elements {
+		switch element := element.(type) {
+		case nil:
+		case []any:
+			// Elements of multidimensional arrays are arrays themselves
+			converted[i] = c.convertArray(elementType, element)
+		default:
+			converted[i] = c.convert(elementType, element)
+		}
+	}
+	return converted
+}
+
+// convertRange renders a range or multirange in the PostgreSQL text form with converted bounds
+func (c *Converters) convertRange(boundType string, value any) any {
+	switch v := value.(type) {
+	case pgtype.Range[any]:
+		return c.rangeString(boundType, v)
+	case pgtype.Multirange[pgtype.Range[any]]:
+		ranges := make([]string, len(v))
+		for i, r := range v {
+			ranges[i] = c.rangeString(boundType, r)
+		}
+		return "{" + strings.Join(ranges, ",") + "}"
+	}
+	return value
+}
+
+func (c *Converters) rangeString(boundType string, r pgtype.Range[any]) string {
+	if r.LowerType == pgtype.Empty {
+		return "empty"
+	}
+
+	bound := func(boundType pgtype.BoundType, value any) string {
+		if boundType == pgtype.Unbounded || value == nil {
+			return ""
+		}
+		text := fmt.Sprint(value)
+		if strings.ContainsAny(text, ` ,()[]"\`) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+		}
+		return text
+	}
+
+	lower, upper := "(", ")"
+	if r.LowerType == pgtype.Inclusive {
+		lower = "["
+	}
+	if r.UpperType == pgtype.Inclusive {
+		upper = "]"
+	}
+	return lower + bound(r.LowerType, c.convert(boundType, r.Lower)) + "," + bound(r.UpperType, c.convert(boundType, r.Upper)) + upper
+}
+
+func convertUuid(c *Converters, value any) any {
+	if uuidBytes, ok := value.([16]uint8); ok {
+		return uuid.UUID(uuidBytes).String()
+	}
+	return value
+}
+
+// convertJson loads json and jsonb documents as their text
+func convertJson(c *Converters, value any) any {
+	bytesData, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return string(bytesData)
+}
+
+// convertNumeric renders numerics as exact decimal strings with their scale, they would lose digits as floats
+func convertNumeric(c *Converters, value any) any {
+	numeric, ok := value.(pgtype.Numeric)
+	if !ok || !numeric.Valid {
+		return value
+	}
+
+	switch {
+	case numeric.NaN:
+		return "NaN"
+	case numeric.InfinityModifier == pgtype.Infinity:
+		return "Infinity"
+	case numeric.InfinityModifier == pgtype.NegativeInfinity:
+		return "-Infinity"
+	}
+
+	digits := numeric.Int.String()
+	sign := ""
+	if rest, ok := strings.CutPrefix(digits, "-"); ok {
+		sign, digits = "-", rest
+	}
+	if numeric.Exp >= 0 {
+		return sign + digits + strings.Repeat("0", int(numeric.Exp))
+	}
+
+	scale := int(-numeric.Exp)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+func convertDate(c *Converters, value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.DateOnly)
+	case pgtype.InfinityModifier:
+		if v == pgtype.Infinity {
+			return infinityDate
+		}
+		return negativeInfinityDate
+	}
+	return value
+}
+
+// convertTimestamp loads timestamps without time zone as their wall clock time
+func convertTimestamp(c *Converters, value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(datetimeLayout)
+	case pgtype.InfinityModifier:
+		return infiniteDatetime(v)
+	}
+	return value
+}
+
+// convertTimestamptz loads timestamps with time zone as the wall clock time of the configured time zone
+func convertTimestamptz(c *Converters, value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		return v.In(c.location).Format(c.timestamptzLayout)
+	case pgtype.InfinityModifier:
+		return infiniteDatetime(v)
+	}
+	return value
+}
+
+func infiniteDatetime(modifier pgtype.InfinityModifier) string {
+	if modifier == pgtype.Infinity {
+		return infinityDatetime
+	}
+	return negativeInfinityDatetime
+}
+
+func convertTime(c *Converters, value any) any {
+	t, ok := value.(pgtype.Time)
+	if !ok || !t.Valid {
+		return value
+	}
+
+	seconds := t.Microseconds / 1e6
+	text := fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	return text + fraction(t.Microseconds%1e6)
+}
+
+// convertInterval renders intervals as ISO 8601 durations with signed components, like the iso_8601 interval style
+func convertInterval(c *Converters, value any) any {
+	interval, ok := value.(pgtype.Interval)
+	if !ok || !interval.Valid {
+		return value
+	}
+
+	var text strings.Builder
+	text.WriteString("P")
+	if years := interval.Months / 12; years != 0 {
+		fmt.Fprintf(&text, "%dY", years)
+	}
+	if months := interval.Months % 12; months != 0 {
+		fmt.Fprintf(&text, "%dM", months)
+	}
+	if interval.Days != 0 {
+		fmt.Fprintf(&text, "%dD", interval.Days)
+	}
+
+	if microseconds := interval.Microseconds; microseconds != 0 {
+		text.WriteString("T")
+		if hours := microseconds / 3600e6; hours != 0 {
+			fmt.Fprintf(&text, "%dH", hours)
+		}
+		if minutes := microseconds / 60e6 % 60; minutes != 0 {
+			fmt.Fprintf(&text, "%dM", minutes)
+		}
+		if rest := microseconds % 60e6; rest != 0 {
+			sign := ""
+			if rest < 0 {
+				sign, rest = "-", -rest
+			}
+			fmt.Fprintf(&text, "%s%d%sS", sign, rest/1e6, fraction(rest%1e6))
+		}
+	}
+
+	if text.Len() == 1 {
+		return "PT0S"
+	}
+	return text.String()
+}
+
+// fraction renders microseconds as a fraction of a second without trailing zeros, empty for none
+func fraction(microseconds int64) string {
+	if microseconds == 0 {
+		return ""
+	}
+	return strings.TrimRight(fmt.Sprintf(".%06d", microseconds), "0")
+}
+
+// convertInet renders host addresses without their prefix length, like PostgreSQL does
+func convertInet(c *Converters, value any) any {
+	prefix, ok := value.(netip.Prefix)
+	if !ok {
+		return value
+	}
+	if prefix.Bits() == prefix.Addr().BitLen() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+func convertCidr(c *Converters, value any) any {
+	if prefix, ok := value.(netip.Prefix); ok {
+		return prefix.String()
+	}
+	return value
+}
+
+func convertMacaddr(c *Converters, value any) any {
+	if addr, ok := value.(net.HardwareAddr); ok {
+		return addr.String()
+	}
+	return value
+}
+
+func convertBytea(c *Converters, value any) any {
+	data, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	if c.byteaEncoding == postgres.ByteaEncodingHex {
+		return hex.EncodeToString(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// convertBits renders bit strings as their digits
+func convertBits(c *Converters, value any) any {
+	bits, ok := value.(pgtype.Bits)
+	if !ok || !bits.Valid {
+		return value
+	}
+
+	var text strings.Builder
+	for i := int32(0); i < bits.Len; i++ {
+		if bits.Bytes[i/8]&(0x80>>(i%8)) != 0 {
+			text.WriteByte('1')
+		} else {
+			text.WriteByte('0')
+		}
+	}
+	return text.String()
+}
+
+// convertHstore loads hstore values as JSON objects, NULL values become null
+func convertHstore(c *Converters, value any) any {
+	text, ok := value.(string)
+	if !ok {
+		return value
+	}
+	pairs, err := parseHstore(text)
+	if err != nil {
+		return text
+	}
+	return convertJson(c, pairs)
+}
+
+// parseArray parses the text form of an array into its elements, nested arrays for multidimensional arrays.
+// Elements are text and NULL elements nil.
+func parseArray(text string) ([]any, error) {
+	// Arrays with lower bounds other than 1 are prefixed with their dimensions
+	if strings.HasPrefix(text, "[") {
+		_, rest, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid array %q", text)
+		}
+		text = rest
+	}
+
+	parser := textParser{text: text}
+	elements, err := parser.array()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos != len(parser.text) {
+		return nil, fmt.Errorf("unexpected %q after array", parser.text[parser.pos:])
+	}
+	return elements, nil
+}
+
+// parseHstore parses the text form of an hstore, "key"=>"value" pairs separated by commas
+func parseHstore(text string) (map[string]any, error) {
+	parser := textParser{text: text}
+	pairs := make(map[string]any)
+	for {
+		parser.skipSpaces()
+		if parser.pos == len(parser.text) {
+			return pairs, nil
+		}
+		if len(pairs) > 0 {
+			if !parser.consume(",") {
+				return nil, fmt.Errorf("expected , at %d of hstore", parser.pos)
+			}
+			parser.skipSpaces()
+		}
+
+		key, _, err := parser.element(`=`)
+		if err != nil {
+			return nil, err
+		}
+		parser.skipSpaces()
+		if !parser.consume("=>") {
+			return nil, fmt.Errorf("expected => at %d of hstore", parser.pos)
+		}
+		parser.skipSpaces()
+		value, quoted, err := parser.element(`,`)
+		if err != nil {
+			return nil, err
+		}
+		if !quoted && strings.EqualFold(value, "NULL") {
+			pairs[key] = nil
+		} else {
+			pairs[key] = value
+		}
+	}
+}
+
+// textParser reads the text forms of arrays and hstores
+type textParser struct {
+	text string
+	pos  int
+}
+
+func (p *textParser) consume(token string) bool {
+	if strings.HasPrefix(p.text[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *textParser) skipSpaces() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *textParser) array() ([]any, error) {
+	if !p.consume("{") {
+		return nil, fmt.Errorf("expected { at %d of array", p.pos)
+	}
+
+	elements := []any{}
+	if p.consume("}") {
+		return elements, nil
+	}
+	for {
+		if strings.HasPrefix(p.text[p.pos:], "{") {
+			nested, err := p.array()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, nested)
+		} else {
+			element, quoted, err := p.element(`,}`)
+			if err != nil {
+				return nil, err
+			}
+			if !quoted && strings.EqualFold(element, "NULL") {
+				elements = append(elements, nil)
+			} else {
+				elements = append(elements, element)
+			}
+		}
+
+		if p.consume("}") {
+			return elements, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected , or } at %d of array", p.pos)
+		}
+	}
+}
+
+// element reads a double quoted element with backslash escapes, or an unquoted one up to one of the terminators
+func (p *textParser) element(terminators string) (string, bool, error) {
+	if !p.consume(`"`) {
+		end := p.pos
+		for end < len(p.text) && !strings.ContainsRune(terminators, rune(p.text[end])) {
+			end++
+		}
+		element := strings.TrimSpace(p.text[p.pos:end])
+		p.pos = end
+		return element, false, nil
+	}
+
+	var element strings.Builder
+	for p.pos < len(p.text) {
+		char := p.text[p.pos]
+		p.pos++
+		switch char {
+		case '"':
+			return element.String(), true, nil
+		case '\\':
+			if p.pos < len(p.text) {
+				element.WriteByte(p.text[p.pos])
+				p.pos++
+			}
+		default:
+			element.WriteByte(char)
+		}
+	}
+	return "", true, fmt.Errorf("unterminated quoted element %q", p.text)
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"math/big"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/sources/postgres"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// converterCase is a value of a column and the value it is loaded as. Text values are decoded like the
+// values of types pgx does not know, such as domains, and the values sent by logical replication.
+type converterCase struct {
+	name     string
+	dataType string
+	value    any
+	want     any
+}
+
+func runConverterCases(t *testing.T, converters *Converters, cases []converterCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := converters.Convert(dtos.ColumnInfo{Name: "value", DataType: tc.dataType}, tc.value)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Convert(%s, %#v) = %#v, want %#v", tc.dataType, tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func newTestConverters(t *testing.T, config postgres.ConversionConfiguration) *Converters {
+	t.Helper()
+	converters, err := NewConverters(config)
+	if err != nil {
+		t.Fatalf("NewConverters: %v", err)
+	}
+	return converters
+}
+
+func TestConvertNumeric(t *testing.T) {
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{}), []converterCase{
+		{"scale is kept", "numeric", "1.500", "1.500"},
+		{"negative fraction", "numeric", "-0.05", "-0.05"},
+		{"integer", "numeric", "42", "42"},
+		{"positive exponent", "numeric", pgtype.Numeric{Int: big.NewInt(12), Exp: 3, Valid: true}, "12000"},
+		{"leading zeros", "numeric", pgtype.Numeric{Int: big.NewInt(7), Exp: -4, Valid: true}, "0.0007"},
+		{"negative leading zeros", "numeric", pgtype.Numeric{Int: big.NewInt(-7), Exp: -4, Valid: true}, "-0.0007"},
+		{"NaN", "numeric", "NaN", "NaN"},
+		{"infinity", "numeric", "Infinity", "Infinity"},
+		{"negative infinity", "numeric", "-Infinity", "-Infinity"},
+		{"NULL", "numeric", nil, nil},
+	})
+}
+
+func TestConvertDateTime(t *testing.T) {
+	converters := newTestConverters(t, postgres.ConversionConfiguration{Timezone: "America/New_York"})
+	runConverterCases(t, converters, []converterCase{
+		{"date", "date", "2024-02-29", "2024-02-29"},
+		{"infinite date", "date", "infinity", "9999-12-31"},
+		{"negative infinite date", "date", "-infinity", "0000-01-01"},
+		{"timestamp keeps its wall clock", "timestamp", "2024-03-10 12:00:00.120", "2024-03-10 12:00:00.12"},
+		{"infinite timestamp", "timestamp", "infinity", "9999-12-31 23:59:59.999999"},
+		{"timestamptz in the configured zone", "timestamptz", "2024-03-10 12:00:00+00", "2024-03-10 08:00:00"},
+		{"timestamptz before the DST change", "timestamptz", "2024-03-10 06:59:59.5+00", "2024-03-10 01:59:59.5"},
+		{"negative infinite timestamptz", "timestamptz", "-infinity", "0000-01-01 00:00:00"},
+		{"time", "time", "13:45:06", "13:45:06"},
+		{"time with microseconds", "time", "00:00:00.000250", "00:00:00.00025"},
+	})
+
+	// Key values keep their offset so they are bound again exactly
+	runConverterCases(t, converters.forKeys(), []converterCase{
+		{"timestamptz key", "timestamptz", "2024-07-01 12:00:00+00", "2024-07-01 08:00:00-04:00"},
+	})
+}
+
+func TestConvertInterval(t *testing.T) {
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{}), []converterCase{
+		{"zero", "interval", "00:00:00", "PT0S"},
+		{"positive", "interval", "1 year 2 mons 3 days 04:05:06.5", "P1Y2M3DT4H5M6.5S"},
+		{"negative", "interval", "-1 years -2 mons -3 days -04:05:06", "P-1Y-2M-3DT-4H-5M-6S"},
+		{"mixed signs", "interval", "1 mon -3 days 01:00:00", "P1M-3DT1H"},
+		{"negative fraction of a second", "interval", "-00:00:01.5", "PT-1.5S"},
+		{"decoded", "interval", pgtype.Interval{Months: -13, Days: 2, Microseconds: -90e6, Valid: true}, "P-1Y-1M2DT-1M-30S"},
+	})
+}
+
+func TestConvertNetwork(t *testing.T) {
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{}), []converterCase{
+		{"inet host", "inet", "192.168.0.1/32", "192.168.0.1"},
+		{"inet host without prefix", "inet", "192.168.0.1", "192.168.0.1"},
+		{"inet address with prefix", "inet", "10.0.0.5/8", "10.0.0.5/8"},
+		{"inet network", "inet", "192.168.0.0/24", "192.168.0.0/24"},
+		{"inet ipv6 host", "inet", "::1/128", "::1"},
+		{"decoded inet", "inet", netip.MustParsePrefix("2001:db8::/32"), "2001:db8::/32"},
+		{"cidr", "cidr", "10.0.0.0/8", "10.0.0.0/8"},
+		{"cidr host", "cidr", "10.1.2.3/32", "10.1.2.3/32"},
+		{"macaddr", "macaddr", "08:00:2B:01:02:03", "08:00:2b:01:02:03"},
+		{"decoded macaddr8", "macaddr8", net.HardwareAddr{0x08, 0x00, 0x2b, 0x01, 0x02, 0x03, 0x04, 0x05}, "08:00:2b:01:02:03:04:05"},
+	})
+}
+
+func TestConvertBytea(t *testing.T) {
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{ByteaEncoding: postgres.ByteaEncodingHex}), []converterCase{
+		{"hex", "bytea", []byte{0x00, 0x01, 0xff}, "0001ff"},
+		{"hex from text", "bytea", `\x0001ff`, "0001ff"},
+		{"empty hex", "bytea", []byte{}, ""},
+	})
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{ByteaEncoding: postgres.ByteaEncodingBase64}), []converterCase{
+		{"base64", "bytea", []byte{0x00, 0x01, 0xff}, "AAH/"},
+		{"base64 from text", "bytea", `\x0001ff`, "AAH/"},
+	})
+}
+
+func TestConvertRange(t *testing.T) {
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{Timezone: "Europe/Paris"}), []converterCase{
+		{"empty", "int4range", "empty", "empty"},
+		{"inclusive lower exclusive upper", "int4range", "[1,5)", "[1,5)"},
+		{"unbounded lower", "int8range", "(,5)", "(,5)"},
+		{"unbounded upper", "daterange", "[2024-01-01,)", "[2024-01-01,)"},
+		{"unbounded", "numrange", "(,)", "(,)"},
+		{"exclusive numeric bounds", "numrange", "(1.50,2.5]", "(1.50,2.5]"},
+		{"timestamp bounds are quoted", "tsrange", `["2024-01-01 00:00:00","2024-01-02 00:00:00")`, `["2024-01-01 00:00:00","2024-01-02 00:00:00")`},
+		{"timestamptz bounds in the configured zone", "tstzrange", `["2024-01-01 00:00:00+00",)`, `["2024-01-01 01:00:00",)`},
+		{"multirange", "int4multirange", "{[1,3),[5,7)}", "{[1,3),[5,7)}"},
+		{"empty multirange", "nummultirange", "{}", "{}"},
+		{"date multirange", "datemultirange", "{[2024-01-01,2024-02-01),[2024-03-01,)}", "{[2024-01-01,2024-02-01),[2024-03-01,)}"},
+	})
+}
+
+func TestConvertHstore(t *testing.T) {
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{}), []converterCase{
+		{"empty", "hstore", "", "{}"},
+		{"NULL values", "hstore", `"a"=>"1", "b"=>NULL, "c"=>"NULL"`, `{"a":"1","b":null,"c":"NULL"}`},
+		{"escaped quotes", "hstore", `"k\"ey"=>"va,l\\ue"`, `{"k\"ey":"va,l\\ue"}`},
+		{"invalid is kept", "hstore", `"a"=`, `"a"=`},
+	})
+}
+
+func TestConvertArray(t *testing.T) {
+	runConverterCases(t, newTestConverters(t, postgres.ConversionConfiguration{}), []converterCase{
+		{"empty", "_int4", "{}", []any{}},
+		{"NULL elements", "_text", `{a,NULL,"NULL",""}`, []any{"a", nil, "NULL", ""}},
+		{"nested", "_numeric", "{{1.50,NULL},{2,-0.5}}", []any{[]any{"1.50", nil}, []any{"2", "-0.5"}}},
+		{"lower bounds", "_int4", "[0:1]={1,2}", []any{"1", "2"}},
+		{"uuid", "_uuid", "{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11,NULL}", []any{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", nil}},
+		{"decoded uuid", "_uuid", []any{[16]uint8{0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11}, nil}, []any{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", nil}},
+		// Arrays of domains are read with the type of the domain and arrive as text
+		{"domain over numeric", "_numeric", `{"1.50",NaN,NULL}`, []any{"1.50", "NaN", nil}},
+		{"domain over timestamp", "_timestamp", `{"2024-01-01 10:00:00.500",infinity}`, []any{"2024-01-01 10:00:00.5", "9999-12-31 23:59:59.999999"}},
+		{"invalid is kept", "_int4", "{1,2", "{1,2"},
+	})
+}
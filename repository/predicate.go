@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"fmt"
+	"migration-tool-go/dtos"
+	"strconv"
+	"strings"
+)
+
+// bindPredicates ANDs the predicates together, shifting their placeholders past the first offset parameters
+func bindPredicates(predicates []dtos.Predicate, offset int) (string, []any) {
+	var clauses []string
+	var args []any
+
+	for _, predicate := range predicates {
+		clause := shiftPlaceholders(predicate.Clause, offset+len(args))
+		clauses = append(clauses, fmt.Sprintf("(%s)", clause))
+		args = append(args, predicate.Args...)
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// shiftPlaceholders adds shift to the $n placeholders of an SQL clause. String literals, quoted identifiers,
+// dollar quoted bodies and comments are copied as they are, so a $1 inside them is left alone.
+func shiftPlaceholders(clause string, shift int) string {
+	var shifted strings.Builder
+	for pos := 0; pos < len(clause); {
+		end := pos + 1
+		switch char := clause[pos]; {
+		case char == '\'':
+			// E'' strings escape quotes with backslashes, others only by doubling them
+			escapes := pos > 0 && (clause[pos-1] == 'E' || clause[pos-1] == 'e') && (pos == 1 || !isIdentifierChar(clause[pos-2]))
+			end = quotedEnd(clause, pos, '\'', escapes)
+		case char == '"':
+			end = quotedEnd(clause, pos, '"', false)
+		case strings.HasPrefix(clause[pos:], "--"):
+			end = strings.IndexByte(clause[pos:], '\n') + pos + 1
+			if end == pos {
+				end = len(clause)
+			}
+		case strings.HasPrefix(clause[pos:], "/*"):
+			end = commentEnd(clause, pos)
+		case char == '$' && (pos == 0 || !isIdentifierChar(clause[pos-1])):
+			digits := pos + 1
+			for digits < len(clause) && clause[digits] >= '0' && clause[digits] <= '9' {
+				digits++
+			}
+			if digits > pos+1 {
+				n, _ := strconv.Atoi(clause[pos+1 : digits])
+				fmt.Fprintf(&shifted, "$%d", n+shift)
+				pos = digits
+				continue
+			}
+			end = dollarQuotedEnd(clause, pos)
+		}
+		shifted.WriteString(clause[pos:end])
+		pos = end
+	}
+	return shifted.String()
+}
+
+// isIdentifierChar reports whether the byte can continue an unquoted identifier, which may contain $
+func isIdentifierChar(char byte) bool {
+	return char == '_' || char == '$' || char >= 0x80 ||
+		(char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+}
+
+// quotedEnd returns the end of the quoted section starting at pos, doubled quotes and escaped characters stay inside
+func quotedEnd(clause string, pos int, quote byte, escapes bool) int {
+	for end := pos + 1; end < len(clause); end++ {
+		switch {
+		case escapes && clause[end] == '\\':
+			end++
+		case clause[end] == quote:
+			if end+1 < len(clause) && clause[end+1] == quote {
+				end++
+				continue
+			}
+			return end + 1
+		}
+	}
+	return len(clause)
+}
+
+// commentEnd returns the end of the block comment starting at pos, block comments nest
+func commentEnd(clause string, pos int) int {
+	depth := 0
+	for end := pos; end+1 < len(clause); end++ {
+		switch clause[end : end+2] {
+		case "/*":
+			depth++
+			end++
+		case "*/":
+			depth--
+			end++
+			if depth == 0 {
+				return end + 1
+			}
+		}
+	}
+	return len(clause)
+}
+
+// dollarQuotedEnd returns the end of the $tag$ quoted body starting at pos, or pos+1 when no tag starts there
+func dollarQuotedEnd(clause string, pos int) int {
+	tagEnd := pos + 1
+	for tagEnd < len(clause) && clause[tagEnd] != '$' {
+		char := clause[tagEnd]
+		if !isIdentifierChar(char) || char == '$' || (tagEnd == pos+1 && char >= '0' && char <= '9') {
+			return pos + 1
+		}
+		tagEnd++
+	}
+	if tagEnd == len(clause) {
+		return pos + 1
+	}
+
+	tag := clause[pos : tagEnd+1]
+	if closing := strings.Index(clause[tagEnd+1:], tag); closing >= 0 {
+		return tagEnd + 1 + closing + len(tag)
+	}
+	return len(clause)
+}
+
+// whereClause builds a WHERE clause from the given conditions and predicates
+func whereClause(conditions []string, params []any, predicates []dtos.Predicate) (string, []any) {
+	if clause, args := bindPredicates(predicates, len(params)); clause != "" {
+		conditions = append(conditions, clause)
+		params = append(params, args...)
+	}
+
+	if len(conditions) == 0 {
+		return "", params
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), params
+}
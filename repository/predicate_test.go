@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"migration-tool-go/dtos"
+	"reflect"
+	"testing"
+)
+
+func TestShiftPlaceholders(t *testing.T) {
+	cases := []struct {
+		name   string
+		clause string
+		shift  int
+		want   string
+	}{
+		{"placeholders", "a > $1 AND b <= $2", 2, "a > $3 AND b <= $4"},
+		{"no shift", "a = $1", 0, "a = $1"},
+		{"multi digit", "a IN ($9, $10)", 1, "a IN ($10, $11)"},
+		{"repeated placeholder", "a = $1 OR b = $1", 3, "a = $4 OR b = $4"},
+		{"string literal", "note <> 'costs $1' AND a = $1", 1, "note <> 'costs $1' AND a = $2"},
+		{"doubled quote", "note = 'it''s $1' AND a = $1", 1, "note = 'it''s $1' AND a = $2"},
+		{"escape string", `note = E'\'$1' AND a = $1`, 1, `note = E'\'$1' AND a = $2`},
+		{"backslash in standard string", `note = 'C:\' AND a = $1`, 1, `note = 'C:\' AND a = $2`},
+		{"quoted identifier", `"price $1" > $1`, 1, `"price $1" > $2`},
+		{"identifier with dollar", "price$1 > $1", 1, "price$1 > $2"},
+		{"dollar quoted", "note <> $$costs $1$$ AND a = $1", 1, "note <> $$costs $1$$ AND a = $2"},
+		{"tagged dollar quoted", "note <> $q$it's $1 $$ $q$ AND a = $1", 1, "note <> $q$it's $1 $$ $q$ AND a = $2"},
+		{"line comment", "a = $1 -- was $2\nAND b = $2", 1, "a = $2 -- was $2\nAND b = $3"},
+		{"trailing line comment", "a = $1 -- $1", 1, "a = $2 -- $1"},
+		{"nested block comment", "a = $1 /* $1 /* $2 */ $3 */ AND b = $2", 1, "a = $2 /* $1 /* $2 */ $3 */ AND b = $3"},
+		{"unterminated literal", "a = $1 AND b = 'x $1", 1, "a = $2 AND b = 'x $1"},
+		{"lone dollar", "a = $ AND b = $1", 1, "a = $ AND b = $2"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := shiftPlaceholders(tc.clause, tc.shift); got != tc.want {
+				t.Errorf("shiftPlaceholders(%q, %d) = %q, want %q", tc.clause, tc.shift, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWhereClause(t *testing.T) {
+	where, params := whereClause([]string{"id > $1"}, []any{10}, []dtos.Predicate{
+		{Clause: "status = $1 AND note <> '$1'", Args: []any{"active"}},
+		{Clause: "updated_at > $1 AND updated_at <= $2", Args: []any{"2024-01-01", "2024-02-01"}},
+	})
+
+	wantWhere := "WHERE id > $1 AND (status = $2 AND note <> '$1') AND (updated_at > $3 AND updated_at <= $4)"
+	if where != wantWhere {
+		t.Errorf("where = %q, want %q", where, wantWhere)
+	}
+	if wantParams := []any{10, "active", "2024-01-01", "2024-02-01"}; !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("params = %v, want %v", params, wantParams)
+	}
+
+	if where, params := whereClause(nil, nil, nil); where != "" || len(params) != 0 {
+		t.Errorf("whereClause without conditions = %q, %v", where, params)
+	}
+}
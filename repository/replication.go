@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"migration-tool-go/dtos"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/samber/lo"
+)
+
+// ReplicationSlotExists reports whether a replication slot with the given name exists
+func (r Repo) ReplicationSlotExists(ctx context.Context, slotName string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", slotName).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up replication slot: %w", err)
+	}
+
+	return exists, nil
+}
+
+// PublicationExists reports whether a publication with the given name exists
+func (r Repo) PublicationExists(ctx context.Context, publication string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = $1)", publication).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up publication: %w", err)
+	}
+
+	return exists, nil
+}
+
+// CreatePublication creates a publication for the given tables
+func (r Repo) CreatePublication(ctx context.Context, publication string, tables []dtos.TableInfo) error {
+	tableNames := lo.Map(tables, func(table dtos.TableInfo, _ int) string {
+		return pgx.Identifier{table.TableSchema, table.TableName}.Sanitize()
+	})
+
+	_, err := r.db.Exec(ctx, fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", pgx.Identifier{publication}.Sanitize(), strings.Join(tableNames, ", ")))
+	if err != nil {
+		return fmt.Errorf("failed to create publication: %w", err)
+	}
+
+	return nil
+}
+
+// ConvertValue converts a decoded column value the same way records read by the repository are converted
+func (r Repo) ConvertValue(columnMetaMap map[string]dtos.ColumnInfo, name string, value any) any {
+	return r.converters.Convert(columnMetaMap[name], value)
+}
@@ -2,26 +2,30 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"migration-tool-go/config"
 	"migration-tool-go/dtos"
+	"migration-tool-go/logger"
+	"sort"
 	"strings"
+	"sync/atomic"
 
-	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/samber/lo"
 )
 
 type Repo struct {
-	db *pgxpool.Pool
+	db         *pgxpool.Pool
+	snapshot   *atomic.Value
+	converters *Converters
 }
 
-func NewRepo(db *pgxpool.Pool) *Repo {
+func NewRepo(db *pgxpool.Pool, converters *Converters) *Repo {
 	return &Repo{
-		db: db,
+		db:         db,
+		snapshot:   &atomic.Value{},
+		converters: converters,
 	}
 }
 
@@ -31,11 +35,14 @@ func (r Repo) GetTableInfo(ctx context.Context, schemas []any) ([]dtos.TableInfo
 			c.table_schema AS schema, 
 			c.table_name AS table, 
 			c.column_name AS column, 
-			c.udt_name AS data_type, 
+			-- udt_name resolves domains to their base type, arrays of domains are resolved here
+			COALESCE('_' || base_type.typname, c.udt_name) AS data_type, 
 			c.ordinal_position, 
 			c.numeric_precision AS precision, 
 			c.numeric_scale AS scale, 
 			c.datetime_precision,
+			c.character_maximum_length AS max_length,
+			c.is_nullable = 'YES' AS is_nullable,
 			CASE 
 				WHEN kcu.column_name IS NOT NULL THEN TRUE 
 				ELSE FALSE 
@@ -52,7 +59,18 @@ func (r Repo) GetTableInfo(ctx context.Context, schemas []any) ([]dtos.TableInfo
 				AND table_schema = c.table_schema 
 				AND constraint_type = 'PRIMARY KEY'
 			)
+		LEFT JOIN pg_namespace udt_namespace ON udt_namespace.nspname = c.udt_schema
+		LEFT JOIN pg_type udt ON udt.typnamespace = udt_namespace.oid AND udt.typname = c.udt_name
+		LEFT JOIN pg_type element_domain ON element_domain.oid = udt.typelem AND udt.typcategory = 'A' AND element_domain.typtype = 'd'
+		LEFT JOIN pg_type base_type ON base_type.oid = element_domain.typbasetype
 		WHERE c.table_schema in (%s) -- ✅ Ensures only the selected schema
+		AND EXISTS (
+			SELECT 1
+			FROM information_schema.tables t
+			WHERE t.table_schema = c.table_schema
+			AND t.table_name = c.table_name
+			AND t.table_type = 'BASE TABLE'
+		)
 		ORDER BY c.table_name, c.ordinal_position;
         `
 
@@ -76,7 +94,8 @@ func (r Repo) GetTableInfo(ctx context.Context, schemas []any) ([]dtos.TableInfo
 		var col dtos.ColumnInfo
 		if err := rows.Scan(
 			&col.Schema, &col.Table, &col.Name, &col.DataType,
-			&col.Ordinal, &col.Precision, &col.Scale, &col.DatetimePrecision, &col.IsPrimaryKey,
+			&col.Ordinal, &col.Precision, &col.Scale, &col.DatetimePrecision,
+			&col.MaxLength, &col.IsNullable, &col.IsPrimaryKey,
 		); err != nil {
 			return nil, err
 		}
@@ -107,16 +126,22 @@ func (r Repo) GetTableInfo(ctx context.Context, schemas []any) ([]dtos.TableInfo
 
 	for _, v := range tableMap {
 		for _, v2 := range v {
-			if v2.TableName == "platform" {
-				tableInfoList = append(tableInfoList, *v2)
-			}
+			tableInfoList = append(tableInfoList, *v2)
 		}
 	}
 
+	// Map iteration is random, keep the table order stable between runs
+	sort.Slice(tableInfoList, func(i, j int) bool {
+		if tableInfoList[i].TableSchema != tableInfoList[j].TableSchema {
+			return tableInfoList[i].TableSchema < tableInfoList[j].TableSchema
+		}
+		return tableInfoList[i].TableName < tableInfoList[j].TableName
+	})
+
 	return tableInfoList, nil
 }
 
-func (r Repo) FetchBatchMultiPrimaryKeys(ctx context.Context, lastIds map[string]any, includeLastId bool, columnMeta []dtos.ColumnInfo, tableSchema string, tableName string, primaryKeys []dtos.PrimaryKey, idBatchSize int) ([]map[string]any, error) {
+func (r Repo) FetchBatchMultiPrimaryKeys(ctx context.Context, lastIds map[string]any, includeLastId bool, columnMeta []dtos.ColumnInfo, tableSchema string, tableName string, primaryKeys []dtos.PrimaryKey, idBatchSize int, predicates []dtos.Predicate) ([]map[string]any, error) {
 	columnMetaMap := lo.SliceToMap(columnMeta, func(item dtos.ColumnInfo) (string, dtos.ColumnInfo) {
 		return item.Name, item
 	})
@@ -168,45 +193,50 @@ func (r Repo) FetchBatchMultiPrimaryKeys(ctx context.Context, lastIds map[string
 			firstCompare,
 			strings.Join(rowComps, ", ")))
 
+	// AND the table predicates into the row comparison
+	where, params := whereClause(whereConditions, params, predicates)
+
 	// Add batch size parameter
 	params = append(params, idBatchSize) // Keep as int for better query planning
+	paramCount = len(params)
 
 	// Construct the final query with proper ordering
 	query := fmt.Sprintf(
-		"SELECT %s FROM %s.%s WHERE %s ORDER BY %s LIMIT $%d",
+		"SELECT %s FROM %s.%s %s ORDER BY %s LIMIT $%d",
 		selectClause,
 		tableSchema,
 		tableName,
-		strings.Join(whereConditions, " AND "),
+		where,
 		selectClause,
 		paramCount,
 	)
 
 	// Execute the query with proper parameter binding
-	rows, err := r.db.Query(ctx, query, params...)
+	rows, err := r.query(ctx, query, params...)
 	if err != nil {
-		log.Printf("Failed to fetch primary key batch: %v", err)
+		logger.Sugar.Errorf("Failed to fetch primary key batch: %v", err)
 		return nil, fmt.Errorf("failed to fetch primary key batch: %w", err)
 	}
 
-	return deserializeRecords(rows, columnMetaMap, selectColumns), nil
+	// Key values are bound again to fetch the next batch
+	return deserializeRecords(rows, r.converters.forKeys(), columnMetaMap, selectColumns)
 }
 
-func (r Repo) FetchBatchPrimaryKeys(ctx context.Context, lastId any, includeLastId bool, tableSchema string, tableName string, primaryKey string, idBatchSize int) ([]any, error) {
+func (r Repo) FetchBatchPrimaryKeys(ctx context.Context, lastId any, includeLastId bool, tableSchema string, tableName string, primaryKey string, idBatchSize int, predicates []dtos.Predicate) ([]any, error) {
 	// Build the query with proper parameter binding
-	var query string
+	compare := ">"
 	if includeLastId {
-		query = fmt.Sprintf(
-			"SELECT %s FROM %s.%s WHERE %s >= $1 ORDER BY %s LIMIT $2",
-			primaryKey, tableSchema, tableName, primaryKey, primaryKey,
-		)
-	} else {
-		query = fmt.Sprintf(
-			"SELECT %s FROM %s.%s WHERE %s > $1 ORDER BY %s LIMIT $2",
-			primaryKey, tableSchema, tableName, primaryKey, primaryKey,
-		)
+		compare = ">="
 	}
 
+	where, params := whereClause([]string{fmt.Sprintf("%s %s $1", primaryKey, compare)}, []any{lastId}, predicates)
+	params = append(params, idBatchSize)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s.%s %s ORDER BY %s LIMIT $%d",
+		primaryKey, tableSchema, tableName, where, primaryKey, len(params),
+	)
+
 	// Add query hints for better performance with indexes
 	if strings.Contains(strings.ToLower(primaryKey), "uuid") {
 		// For UUID columns, force index scan
@@ -214,31 +244,39 @@ func (r Repo) FetchBatchPrimaryKeys(ctx context.Context, lastId any, includeLast
 	}
 
 	// Execute the query with proper parameter binding
-	rows, err := r.db.Query(ctx, query, lastId, idBatchSize)
+	rows, err := r.query(ctx, query, params...)
 	if err != nil {
-		log.Printf("Failed to fetch primary key batch: %v", err)
+		logger.Sugar.Errorf("Failed to fetch primary key batch: %v", err)
 		return nil, fmt.Errorf("failed to fetch primary key batch: %w", err)
 	}
 
 	//log.Printf("Fetching %d UUIDs took %s", idBatchSize, time.Now().Sub(startTime))
 
+	defer rows.Close()
+
 	var ids []any
 	for rows.Next() {
 		var id any
 		if err := rows.Scan(&id); err != nil {
-			log.Printf("Row Scan Error: %v", err)
-			continue
+			return nil, fmt.Errorf("failed to scan primary key: %w", err)
 		}
 		ids = append(ids, id)
 	}
-	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch primary key batch: %w", err)
+	}
 
 	return ids, nil
 }
 
-func (r Repo) GetFirstIdByPrimaryKey(ctx context.Context, schemaName string, tableName string, colName string) (any, error) {
+// GetFirstIdByPrimaryKey returns the lowest key of the rows matching the predicates, nil for no rows
+func (r Repo) GetFirstIdByPrimaryKey(ctx context.Context, schemaName string, tableName string, colName string, predicates []dtos.Predicate) (any, error) {
 	var id any
-	err := config.Db.QueryRow(ctx, fmt.Sprintf("SELECT %s FROM %s.%s ORDER BY %s ASC LIMIT 1", colName, schemaName, tableName, colName)).Scan(&id)
+	where, params := whereClause(nil, nil, predicates)
+	err := r.queryRow(ctx, fmt.Sprintf("SELECT %s FROM %s.%s %s ORDER BY %s ASC LIMIT 1", colName, schemaName, tableName, where, colName), params...).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +284,130 @@ func (r Repo) GetFirstIdByPrimaryKey(ctx context.Context, schemaName string, tab
 	return id, nil
 }
 
-func (r Repo) GetFirstIdsByMultiPrimaryKeys(ctx context.Context, columnMeta []dtos.ColumnInfo, schemaName string, tableName string, keys []dtos.PrimaryKey) (map[string]any, error) {
+// GetMaxValue returns the highest value of a column among the rows matching the predicates, nil for no rows
+func (r Repo) GetMaxValue(ctx context.Context, schemaName string, tableName string, colName string, predicates []dtos.Predicate) (any, error) {
+	var value any
+	where, params := whereClause(nil, nil, predicates)
+	err := r.queryRow(ctx, fmt.Sprintf("SELECT MAX(%s) FROM %s.%s %s", colName, schemaName, tableName, where), params...).Scan(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// CountRows returns the number of rows of a table matching the predicates
+func (r Repo) CountRows(ctx context.Context, schemaName string, tableName string, predicates []dtos.Predicate) (int64, error) {
+	var count int64
+	where, params := whereClause(nil, nil, predicates)
+	err := r.queryRow(ctx, fmt.Sprintf("SELECT count(*) FROM %s.%s %s", schemaName, tableName, where), params...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CheckPredicates plans and runs a query of the table restricted by the predicates without reading any row,
+// so unknown columns, syntax errors and arguments not matching the placeholders are reported
+func (r Repo) CheckPredicates(ctx context.Context, schemaName string, tableName string, predicates []dtos.Predicate) error {
+	where, params := whereClause(nil, nil, predicates)
+	rows, err := r.db.Query(ctx, fmt.Sprintf("SELECT 1 FROM %s.%s %s LIMIT 0", schemaName, tableName, where), params...)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	return rows.Err()
+}
+
+// GetTableEstimate returns the planner row estimate of a table, negative when it was never analyzed,
+// and its total size on disk including indexes and TOAST
+func (r Repo) GetTableEstimate(ctx context.Context, schemaName string, tableName string) (float64, int64, error) {
+	query := `
+		SELECT c.reltuples::float8, pg_total_relation_size(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2
+	`
+
+	var reltuples float64
+	var totalBytes int64
+	if err := r.db.QueryRow(ctx, query, schemaName, tableName).Scan(&reltuples, &totalBytes); err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch the size estimate of %s.%s: %w", schemaName, tableName, err)
+	}
+
+	return reltuples, totalBytes, nil
+}
+
+// GetUniqueIndexKey returns the name and key columns of the unique index best suited for keyset pagination
+// of a table: valid, non partial, without expressions and on NOT NULL columns only, preferring the fewest
+// columns. name is empty when the table has no such index.
+func (r Repo) GetUniqueIndexKey(ctx context.Context, schemaName string, tableName string) (string, []string, error) {
+	query := `
+		SELECT i.relname, array_agg(a.attname::text ORDER BY k.ord)
+		FROM pg_index x
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_class i ON i.oid = x.indexrelid
+		CROSS JOIN LATERAL unnest(x.indkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE n.nspname = $1
+		AND t.relname = $2
+		AND x.indisunique
+		AND x.indisvalid
+		AND x.indpred IS NULL
+		AND x.indexprs IS NULL
+		AND k.ord <= x.indnkeyatts
+		GROUP BY i.relname
+		HAVING bool_and(a.attnotnull)
+		ORDER BY count(*), i.relname
+		LIMIT 1
+	`
+
+	var name string
+	var columns []string
+	err := r.db.QueryRow(ctx, query, schemaName, tableName).Scan(&name, &columns)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch unique indexes of %s.%s: %w", schemaName, tableName, err)
+	}
+
+	return name, columns, nil
+}
+
+// GetServerVersionNum returns the server_version_num of the server, 140000 for PostgreSQL 14.0
+func (r Repo) GetServerVersionNum(ctx context.Context) (int, error) {
+	var version int
+	if err := r.db.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to fetch the server version: %w", err)
+	}
+	return version, nil
+}
+
+// GetRelationBlocks returns the current number of heap blocks of a table and its estimated row count,
+// the estimate is negative when the table has never been analyzed
+func (r Repo) GetRelationBlocks(ctx context.Context, schemaName string, tableName string) (int64, float64, error) {
+	query := `
+		SELECT pg_relation_size(c.oid) / current_setting('block_size')::bigint, c.reltuples::float8
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2
+	`
+
+	var blocks int64
+	var reltuples float64
+	if err := r.db.QueryRow(ctx, query, schemaName, tableName).Scan(&blocks, &reltuples); err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch the size of %s.%s: %w", schemaName, tableName, err)
+	}
+
+	return blocks, reltuples, nil
+}
+
+// GetFirstIdsByMultiPrimaryKeys returns the lowest keys of the rows matching the predicates, nil for no rows
+func (r Repo) GetFirstIdsByMultiPrimaryKeys(ctx context.Context, columnMeta []dtos.ColumnInfo, schemaName string, tableName string, keys []dtos.PrimaryKey, predicates []dtos.Predicate) (map[string]any, error) {
 
 	columnMetaMap := lo.SliceToMap(columnMeta, func(item dtos.ColumnInfo) (string, dtos.ColumnInfo) {
 		return item.Name, item
@@ -254,14 +415,17 @@ func (r Repo) GetFirstIdsByMultiPrimaryKeys(ctx context.Context, columnMeta []dt
 
 	keysStr := strings.Join(lo.Map(keys, func(key dtos.PrimaryKey, index int) string { return key.ColumnName }), ", ")
 
-	row := config.Db.QueryRow(ctx, fmt.Sprintf("SELECT %s FROM %s.%s ORDER BY %s ASC LIMIT 1", keysStr, schemaName, tableName, keysStr))
+	where, params := whereClause(nil, nil, predicates)
+	row := r.queryRow(ctx, fmt.Sprintf("SELECT %s FROM %s.%s %s ORDER BY %s ASC LIMIT 1", keysStr, schemaName, tableName, where, keysStr), params...)
 
 	var values []any
 	for _ = range keys {
 		values = append(values, new(any))
 	}
 
-	if err := row.Scan(values...); err != nil {
+	if err := row.Scan(values...); errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -272,14 +436,14 @@ func (r Repo) GetFirstIdsByMultiPrimaryKeys(ctx context.Context, columnMeta []dt
 			firstIds[key.ColumnName] = nil
 			continue
 		}
-		firstIds[key.ColumnName] = getRecord(columnMetaMap, key.ColumnName, values[i])
+		firstIds[key.ColumnName] = r.converters.forKeys().Convert(columnMetaMap[key.ColumnName], values[i])
 	}
 
 	return firstIds, nil
 
 }
 
-func (r Repo) GetRecordsById(ctx context.Context, columnMeta []dtos.ColumnInfo, colName string, tableSchema string, tableName string, idStart any, idEnd any) ([]map[string]any, error) {
+func (r Repo) GetRecordsById(ctx context.Context, columnMeta []dtos.ColumnInfo, colName string, tableSchema string, tableName string, idStart any, idEnd any, predicates []dtos.Predicate) ([]map[string]any, error) {
 	// Fetch 100K UUIDs
 
 	columnMetaMap := lo.SliceToMap(columnMeta, func(item dtos.ColumnInfo) (string, dtos.ColumnInfo) {
@@ -293,16 +457,43 @@ func (r Repo) GetRecordsById(ctx context.Context, columnMeta []dtos.ColumnInfo,
 
 	columnList := strings.Join(columnNames, ", ")
 
-	rows, err := config.Db.Query(ctx, fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s >= $1 AND %s <= $2 ORDER BY %s", columnList, tableSchema, tableName, colName, colName, colName), idStart, idEnd)
+	where, params := whereClause([]string{fmt.Sprintf("%s >= $1 AND %s <= $2", colName, colName)}, []any{idStart, idEnd}, predicates)
+
+	rows, err := r.query(ctx, fmt.Sprintf("SELECT %s FROM %s.%s %s ORDER BY %s", columnList, tableSchema, tableName, where, colName), params...)
+	if err != nil {
+		logger.Sugar.Errorf("DB Query Error: %v", err)
+		return nil, err
+	}
+
+	return deserializeRecords(rows, r.converters, columnMetaMap, columnNames)
+}
+
+// GetRecordsByCtidRange returns the rows stored in the heap blocks [startBlock, endBlock), endBlock nil reads to the end
+func (r Repo) GetRecordsByCtidRange(ctx context.Context, columnMeta []dtos.ColumnInfo, tableSchema string, tableName string, startBlock any, endBlock any, predicates []dtos.Predicate) ([]map[string]any, error) {
+	columnMetaMap := lo.SliceToMap(columnMeta, func(item dtos.ColumnInfo) (string, dtos.ColumnInfo) {
+		return item.Name, item
+	})
+
+	columnNames := lo.Map(columnMeta, func(item dtos.ColumnInfo, index int) string { return item.Name })
+
+	conditions := []string{"ctid >= $1::tid"}
+	params := []any{fmt.Sprintf("(%v,0)", startBlock)}
+	if endBlock != nil {
+		conditions = append(conditions, "ctid < $2::tid")
+		params = append(params, fmt.Sprintf("(%v,0)", endBlock))
+	}
+	where, params := whereClause(conditions, params, predicates)
+
+	rows, err := r.query(ctx, fmt.Sprintf("SELECT %s FROM %s.%s %s", strings.Join(columnNames, ", "), tableSchema, tableName, where), params...)
 	if err != nil {
-		log.Printf("DB Query Error: %v", err)
+		logger.Sugar.Errorf("DB Query Error: %v", err)
 		return nil, err
 	}
 
-	return deserializeRecords(rows, columnMetaMap, columnNames), nil
+	return deserializeRecords(rows, r.converters, columnMetaMap, columnNames)
 }
 
-func (r Repo) GetRecordsByMultiPrimaryKeys(ctx context.Context, columns []dtos.ColumnInfo, keys []dtos.PrimaryKey, tableSchema string, tableName string, idsStart map[string]any, idsEnd map[string]any) ([]map[string]any, error) {
+func (r Repo) GetRecordsByMultiPrimaryKeys(ctx context.Context, columns []dtos.ColumnInfo, keys []dtos.PrimaryKey, tableSchema string, tableName string, idsStart map[string]any, idsEnd map[string]any, predicates []dtos.Predicate) ([]map[string]any, error) {
 	columnMetaMap := lo.SliceToMap(columns, func(item dtos.ColumnInfo) (string, dtos.ColumnInfo) {
 		return item.Name, item
 	})
@@ -336,37 +527,23 @@ func (r Repo) GetRecordsByMultiPrimaryKeys(ctx context.Context, columns []dtos.C
 
 	keysStr := strings.Join(lo.Map(keys, func(key dtos.PrimaryKey, index int) string { return key.ColumnName }), ", ")
 
-	rows, err := config.Db.Query(ctx,
-		fmt.Sprintf("SELECT %s FROM %s.%s WHERE (%s) >= (%s) AND (%s) <= (%s) ORDER BY %s",
-			columnList, tableSchema, tableName, keysStr, rhsValuePlaceHolder1Str, keysStr, rhsValuePlaceHolder2Str, keysStr), value1...)
+	where, params := whereClause([]string{fmt.Sprintf("(%s) >= (%s) AND (%s) <= (%s)", keysStr, rhsValuePlaceHolder1Str, keysStr, rhsValuePlaceHolder2Str)}, value1, predicates)
+
+	rows, err := r.query(ctx,
+		fmt.Sprintf("SELECT %s FROM %s.%s %s ORDER BY %s",
+			columnList, tableSchema, tableName, where, keysStr), params...)
 	if err != nil {
-		log.Printf("Failed to fetch records by multi primary keys: %v", err)
+		logger.Sugar.Errorf("Failed to fetch records by multi primary keys: %v", err)
 		return nil, err
 	}
 
-	return deserializeRecords(rows, columnMetaMap, columnNames), nil
+	return deserializeRecords(rows, r.converters, columnMetaMap, columnNames)
 }
 
-func getRecord(meta map[string]dtos.ColumnInfo, name string, rawValue interface{}) any {
-	switch meta[name].DataType {
-	case "uuid":
-		if uuidBytes, ok := (*rawValue.(*interface{})).([16]uint8); ok {
-			return uuid.UUID(uuidBytes).String()
-		} else {
-			return rawValue
-		}
-	case "json", "jsonb":
-		bytesData, err := json.Marshal((*rawValue.(*interface{})))
-
-		if err == nil {
-			return string(bytesData)
-		}
-	}
-
-	return rawValue
-}
+// deserializeRecords converts every row, a row that cannot be scanned fails the whole result
+func deserializeRecords(rows pgx.Rows, converters *Converters, columnMetaMap map[string]dtos.ColumnInfo, columnNames []string) ([]map[string]any, error) {
+	defer rows.Close()
 
-func deserializeRecords(rows pgx.Rows, columnMetaMap map[string]dtos.ColumnInfo, columnNames []string) []map[string]any {
 	var records []map[string]any
 
 	for rows.Next() {
@@ -378,8 +555,7 @@ func deserializeRecords(rows pgx.Rows, columnMetaMap map[string]dtos.ColumnInfo,
 		}
 
 		if err := rows.Scan(values...); err != nil {
-			log.Printf("Row Scan Error: %v", err)
-			continue
+			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
 		record := make(map[string]any)
@@ -390,11 +566,14 @@ func deserializeRecords(rows pgx.Rows, columnMetaMap map[string]dtos.ColumnInfo,
 				continue
 			}
 
-			record[colName] = getRecord(columnMetaMap, colName, values[i])
+			record[colName] = converters.Convert(columnMetaMap[colName], values[i])
 
 		}
 		records = append(records, record)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
 
-	return records
+	return records, nil
 }
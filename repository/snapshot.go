@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetSnapshot makes every following data query read under the exported snapshot, an empty name reads the latest data
+func (r *Repo) SetSnapshot(snapshotName string) {
+	r.snapshot.Store(snapshotName)
+}
+
+func (r Repo) snapshotName() string {
+	name, _ := r.snapshot.Load().(string)
+	return name
+}
+
+// query runs a data query, inside a transaction importing the snapshot when one is set
+func (r Repo) query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	snapshotName := r.snapshotName()
+	if snapshotName == "" {
+		return r.db.Query(ctx, sql, args...)
+	}
+
+	tx, err := r.beginSnapshotTx(ctx, snapshotName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	return &snapshotRows{Rows: rows, tx: tx, ctx: ctx}, nil
+}
+
+// queryRow runs a single row data query, inside a transaction importing the snapshot when one is set
+func (r Repo) queryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	snapshotName := r.snapshotName()
+	if snapshotName == "" {
+		return r.db.QueryRow(ctx, sql, args...)
+	}
+
+	tx, err := r.beginSnapshotTx(ctx, snapshotName)
+	if err != nil {
+		return errRow{err: err}
+	}
+
+	return &snapshotRow{Row: tx.QueryRow(ctx, sql, args...), tx: tx, ctx: ctx}
+}
+
+// beginSnapshotTx starts a read only repeatable read transaction on a pooled connection and imports the snapshot
+func (r Repo) beginSnapshotTx(ctx context.Context, snapshotName string) (pgx.Tx, error) {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin snapshot transaction: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", snapshotName)); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, fmt.Errorf("failed to import snapshot %s: %w", snapshotName, err)
+	}
+
+	return tx, nil
+}
+
+// snapshotRows ends the snapshot transaction once the rows are closed
+type snapshotRows struct {
+	pgx.Rows
+	tx  pgx.Tx
+	ctx context.Context
+}
+
+func (s *snapshotRows) Close() {
+	s.Rows.Close()
+	_ = s.tx.Rollback(s.ctx)
+}
+
+// snapshotRow ends the snapshot transaction once the row is scanned
+type snapshotRow struct {
+	pgx.Row
+	tx  pgx.Tx
+	ctx context.Context
+}
+
+func (s *snapshotRow) Scan(dest ...any) error {
+	err := s.Row.Scan(dest...)
+	_ = s.tx.Rollback(s.ctx)
+	return err
+}
+
+type errRow struct {
+	err error
+}
+
+func (e errRow) Scan(dest ...any) error {
+	return e.err
+}
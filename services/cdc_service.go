@@ -0,0 +1,587 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"migration-tool-go/config"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/destinations/doris"
+	"migration-tool-go/dtos/sources/postgres"
+	"migration-tool-go/logger"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/samber/lo"
+)
+
+var labelUnsafeCharacters = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// cdcService streams the changes of the source tables from a pgoutput replication slot into the sink
+type cdcService struct {
+	source     *postgresMigration
+	sink       Sink
+	cdcConfig  postgres.CdcConfiguration
+	tables     map[string]dtos.TableInfo
+	columnMeta map[string]map[string]dtos.ColumnInfo
+	relations  map[uint32]*pglogrepl.RelationMessage
+	typeMap    *pgtype.Map
+	batch      *cdcBatch
+	// confirmedLSN is the end LSN of the last transaction loaded into the sink
+	confirmedLSN pglogrepl.LSN
+	// ackLSN is the position reported to the server, it never passes unloaded changes
+	ackLSN      pglogrepl.LSN
+	toastWarned map[string]bool
+}
+
+// cdcBatch buffers the changes of committed transactions until they are flushed to the sink
+type cdcBatch struct {
+	records map[string][]map[string]any
+	count   int
+	endLSN  pglogrepl.LSN
+}
+
+func newCdcBatch() *cdcBatch {
+	return &cdcBatch{records: make(map[string][]map[string]any)}
+}
+
+// NewCdcService creates the change data capture service on top of a postgres source
+func NewCdcService(source Source, sink Sink) (*cdcService, error) {
+	postgresSource, ok := source.(*postgresMigration)
+	if !ok {
+		return nil, fmt.Errorf("cdc requires a postgres source, got %T", source)
+	}
+
+	cdcConfig := postgresSource.configuration.Cdc
+	cdcConfig.SetDefaults()
+
+	logger.Sugar.Infof("CDC service initialized with slot=%s, publication=%s, batch size=%d, flush interval=%s",
+		cdcConfig.SlotName,
+		cdcConfig.Publication,
+		cdcConfig.BatchSize,
+		cdcConfig.GetFlushInterval())
+
+	return &cdcService{
+		source:      postgresSource,
+		sink:        sink,
+		cdcConfig:   cdcConfig,
+		tables:      make(map[string]dtos.TableInfo),
+		columnMeta:  make(map[string]map[string]dtos.ColumnInfo),
+		relations:   make(map[uint32]*pglogrepl.RelationMessage),
+		typeMap:     pgtype.NewMap(),
+		batch:       newCdcBatch(),
+		toastWarned: make(map[string]bool),
+	}, nil
+}
+
+// Run creates or reuses the replication slot and streams changes until the context is cancelled
+func (c *cdcService) Run(ctx context.Context) error {
+	if err := c.prepare(ctx); err != nil {
+		return err
+	}
+
+	conn, err := config.NewReplicationConnection(ctx, c.source.connectionDetails)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	exists, err := c.source.repo.ReplicationSlotExists(ctx, c.cdcConfig.SlotName)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		logger.Sugar.Infof("Reusing replication slot %s", c.cdcConfig.SlotName)
+	} else {
+		slot, err := pglogrepl.CreateReplicationSlot(ctx, conn, c.cdcConfig.SlotName, "pgoutput", pglogrepl.CreateReplicationSlotOptions{
+			Mode:           pglogrepl.LogicalReplication,
+			SnapshotAction: "NOEXPORT_SNAPSHOT",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create replication slot: %w", err)
+		}
+		logger.Sugar.Infof("Created replication slot %s at consistent point %s", slot.SlotName, slot.ConsistentPoint)
+	}
+
+	return c.stream(ctx, conn, c.confirmedLSN)
+}
+
+// RunWithSnapshot hands a bulk copy over to streaming without a gap or an overlap. It creates a new slot
+// exporting its snapshot, runs copyTables while every source query reads under that snapshot and then
+// streams the changes committed after the slot's consistent point.
+func (c *cdcService) RunWithSnapshot(ctx context.Context, copyTables func(ctx context.Context) error) error {
+	if err := c.prepare(ctx); err != nil {
+		return err
+	}
+
+	exists, err := c.source.repo.ReplicationSlotExists(ctx, c.cdcConfig.SlotName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("replication slot %s already exists, drop it for a new snapshot or use the cdc command to keep streaming from it", c.cdcConfig.SlotName)
+	}
+
+	// The exported snapshot stays valid as long as this connection runs no other command
+	conn, err := config.NewReplicationConnection(ctx, c.source.connectionDetails)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	slot, err := pglogrepl.CreateReplicationSlot(ctx, conn, c.cdcConfig.SlotName, "pgoutput", pglogrepl.CreateReplicationSlotOptions{
+		Mode:           pglogrepl.LogicalReplication,
+		SnapshotAction: "EXPORT_SNAPSHOT",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create replication slot: %w", err)
+	}
+
+	consistentPoint, err := pglogrepl.ParseLSN(slot.ConsistentPoint)
+	if err != nil {
+		return fmt.Errorf("invalid consistent point %q: %w", slot.ConsistentPoint, err)
+	}
+	logger.Sugar.Infof("Created replication slot %s at consistent point %s with exported snapshot %s", slot.SlotName, slot.ConsistentPoint, slot.SnapshotName)
+
+	c.source.repo.SetSnapshot(slot.SnapshotName)
+	err = copyTables(ctx)
+	c.source.repo.SetSnapshot("")
+
+	if err != nil {
+		return fmt.Errorf("bulk copy under snapshot %s failed: %w", slot.SnapshotName, err)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("bulk copy interrupted, replication slot %s must be dropped before starting over", c.cdcConfig.SlotName)
+	}
+
+	// Everything committed before the consistent point is part of the copy
+	if err := CheckpointService.SetConfirmedLSN(c.cdcConfig.SlotName, consistentPoint.String()); err != nil {
+		return fmt.Errorf("failed to persist consistent point: %w", err)
+	}
+	c.confirmedLSN = consistentPoint
+	c.ackLSN = consistentPoint
+
+	logger.Sugar.Infof("Bulk copy completed, streaming changes from consistent point %s", consistentPoint)
+	return c.stream(ctx, conn, consistentPoint)
+}
+
+// prepare discovers the tables to capture, makes sure the publication exists and restores the confirmed LSN
+func (c *cdcService) prepare(ctx context.Context) error {
+	tables, err := c.source.discoverTables(ctx)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("no tables selected for change data capture")
+	}
+
+	for _, table := range tables {
+		key := checkpointKey(table.TableSchema, table.TableName)
+		c.tables[key] = table
+		if predicate, ok := c.source.configuration.GetRowFilter(table.TableSchema, table.TableName); ok {
+			logger.Sugar.Warnf("Row filter %q of %s only applies to the snapshot, every change of the table is streamed", predicate.Clause, key)
+		}
+		c.columnMeta[key] = lo.SliceToMap(table.Columns, func(column dtos.ColumnInfo) (string, dtos.ColumnInfo) {
+			return column.Name, column
+		})
+	}
+
+	exists, err := c.source.repo.PublicationExists(ctx, c.cdcConfig.Publication)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := c.source.repo.CreatePublication(ctx, c.cdcConfig.Publication, tables); err != nil {
+			return err
+		}
+		logger.Sugar.Infof("Created publication %s for %d tables", c.cdcConfig.Publication, len(tables))
+	}
+
+	if lsn, ok := CheckpointService.GetConfirmedLSN(c.cdcConfig.SlotName); ok {
+		confirmedLSN, err := pglogrepl.ParseLSN(lsn)
+		if err != nil {
+			return fmt.Errorf("invalid confirmed LSN %q in state file: %w", lsn, err)
+		}
+		c.confirmedLSN = confirmedLSN
+		c.ackLSN = confirmedLSN
+		logger.Sugar.Infof("Resuming replication slot %s after LSN %s", c.cdcConfig.SlotName, lsn)
+	}
+
+	return nil
+}
+
+// stream consumes the replication stream starting at startLSN
+func (c *cdcService) stream(ctx context.Context, conn *pgconn.PgConn, startLSN pglogrepl.LSN) error {
+	err := pglogrepl.StartReplication(ctx, conn, c.cdcConfig.SlotName, startLSN, pglogrepl.StartReplicationOptions{
+		PluginArgs: []string{
+			"proto_version '1'",
+			fmt.Sprintf("publication_names '%s'", c.cdcConfig.Publication),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start replication: %w", err)
+	}
+	logger.Sugar.Infof("Logical replication started on slot %s at LSN %s", c.cdcConfig.SlotName, startLSN)
+
+	nextStatusDeadline := time.Now().Add(c.cdcConfig.GetStatusInterval())
+	nextFlushDeadline := time.Now().Add(c.cdcConfig.GetFlushInterval())
+	inTransaction := false
+	skipTransaction := false
+	var transactionRecords []cdcChange
+
+	for {
+		if ctx.Err() != nil {
+			return c.shutdown(ctx, conn)
+		}
+
+		if !inTransaction && time.Now().After(nextFlushDeadline) {
+			if err := c.flush(ctx, conn); err != nil {
+				return err
+			}
+			nextFlushDeadline = time.Now().Add(c.cdcConfig.GetFlushInterval())
+		}
+
+		if time.Now().After(nextStatusDeadline) {
+			if err := c.sendStatus(ctx, conn); err != nil {
+				return err
+			}
+			nextStatusDeadline = time.Now().Add(c.cdcConfig.GetStatusInterval())
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, minTime(nextStatusDeadline, nextFlushDeadline))
+		rawMsg, err := conn.ReceiveMessage(receiveCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) || ctx.Err() != nil {
+				continue
+			}
+			return fmt.Errorf("failed to receive replication message: %w", err)
+		}
+
+		if errMsg, ok := rawMsg.(*pgproto3.ErrorResponse); ok {
+			return fmt.Errorf("received postgres WAL error: %+v", errMsg)
+		}
+
+		msg, ok := rawMsg.(*pgproto3.CopyData)
+		if !ok {
+			logger.Sugar.Warnf("Received unexpected replication message: %T", rawMsg)
+			continue
+		}
+
+		switch msg.Data[0] {
+		case pglogrepl.PrimaryKeepaliveMessageByteID:
+			keepalive, err := pglogrepl.ParsePrimaryKeepaliveMessage(msg.Data[1:])
+			if err != nil {
+				return fmt.Errorf("failed to parse keepalive message: %w", err)
+			}
+
+			// Nothing is buffered, the slot can move past WAL that carries no captured change
+			if !inTransaction && c.batch.count == 0 && keepalive.ServerWALEnd > c.ackLSN {
+				c.ackLSN = keepalive.ServerWALEnd
+			}
+			if keepalive.ReplyRequested {
+				nextStatusDeadline = time.Time{}
+			}
+
+		case pglogrepl.XLogDataByteID:
+			xld, err := pglogrepl.ParseXLogData(msg.Data[1:])
+			if err != nil {
+				return fmt.Errorf("failed to parse XLogData: %w", err)
+			}
+
+			logicalMsg, err := pglogrepl.Parse(xld.WALData)
+			if err != nil {
+				return fmt.Errorf("failed to parse logical replication message: %w", err)
+			}
+
+			switch logicalMsg := logicalMsg.(type) {
+			case *pglogrepl.RelationMessage:
+				c.relations[logicalMsg.RelationID] = logicalMsg
+
+			case *pglogrepl.BeginMessage:
+				inTransaction = true
+				// Transactions committed before the confirmed LSN were already loaded by a previous run
+				skipTransaction = logicalMsg.FinalLSN < c.confirmedLSN
+				transactionRecords = nil
+
+			case *pglogrepl.InsertMessage, *pglogrepl.UpdateMessage, *pglogrepl.DeleteMessage:
+				if skipTransaction {
+					continue
+				}
+				changes, err := c.decodeChange(logicalMsg)
+				if err != nil {
+					return err
+				}
+				transactionRecords = append(transactionRecords, changes...)
+
+			case *pglogrepl.TruncateMessage:
+				logger.Sugar.Warnf("Ignoring TRUNCATE of %d relations, truncates are not replicated", logicalMsg.RelationNum)
+
+			case *pglogrepl.CommitMessage:
+				inTransaction = false
+				if skipTransaction {
+					continue
+				}
+
+				for _, change := range transactionRecords {
+					c.batch.records[change.table] = append(c.batch.records[change.table], change.record)
+				}
+				c.batch.count += len(transactionRecords)
+				c.batch.endLSN = logicalMsg.TransactionEndLSN
+				transactionRecords = nil
+
+				if c.batch.count == 0 {
+					c.ackLSN = logicalMsg.TransactionEndLSN
+				} else if c.batch.count >= c.cdcConfig.BatchSize {
+					if err := c.flush(ctx, conn); err != nil {
+						return err
+					}
+					nextFlushDeadline = time.Now().Add(c.cdcConfig.GetFlushInterval())
+				}
+			}
+		}
+	}
+}
+
+// cdcChange is a decoded row change of a captured table
+type cdcChange struct {
+	table  string
+	record map[string]any
+}
+
+// decodeChange converts an INSERT, UPDATE or DELETE message into records carrying the Doris delete sign
+func (c *cdcService) decodeChange(msg pglogrepl.Message) ([]cdcChange, error) {
+	var relationID uint32
+	switch msg := msg.(type) {
+	case *pglogrepl.InsertMessage:
+		relationID = msg.RelationID
+	case *pglogrepl.UpdateMessage:
+		relationID = msg.RelationID
+	case *pglogrepl.DeleteMessage:
+		relationID = msg.RelationID
+	}
+
+	relation, ok := c.relations[relationID]
+	if !ok {
+		return nil, fmt.Errorf("unknown relation ID %d", relationID)
+	}
+
+	key := checkpointKey(relation.Namespace, relation.RelationName)
+	table, captured := c.tables[key]
+	if !captured {
+		return nil, nil
+	}
+
+	switch msg := msg.(type) {
+	case *pglogrepl.InsertMessage:
+		record, _, err := c.decodeTuple(key, relation, msg.Tuple)
+		if err != nil {
+			return nil, err
+		}
+		record[doris.DeleteSignColumn] = 0
+		return []cdcChange{{table: key, record: record}}, nil
+
+	case *pglogrepl.UpdateMessage:
+		var changes []cdcChange
+		record, unchangedToast, err := c.decodeTuple(key, relation, msg.NewTuple)
+		if err != nil {
+			return nil, err
+		}
+
+		if msg.OldTuple != nil {
+			oldRecord, _, err := c.decodeTuple(key, relation, msg.OldTuple)
+			if err != nil {
+				return nil, err
+			}
+
+			// A changed primary key is a delete of the old row followed by an insert of the new one
+			if !samePrimaryKey(table, oldRecord, record) {
+				changes = append(changes, cdcChange{table: key, record: deleteRecord(table, oldRecord)})
+			}
+
+			// Unchanged TOAST values are only available from the old tuple of REPLICA IDENTITY FULL tables
+			for _, column := range unchangedToast {
+				if value, ok := oldRecord[column]; ok {
+					record[column] = value
+				}
+			}
+		}
+
+		if len(unchangedToast) > 0 && msg.OldTuple == nil && !c.toastWarned[key] {
+			logger.Sugar.Warnf("Table %s has unchanged TOAST columns %v in updates, set REPLICA IDENTITY FULL to replicate their values", key, unchangedToast)
+			c.toastWarned[key] = true
+		}
+
+		record[doris.DeleteSignColumn] = 0
+		return append(changes, cdcChange{table: key, record: record}), nil
+
+	case *pglogrepl.DeleteMessage:
+		record, _, err := c.decodeTuple(key, relation, msg.OldTuple)
+		if err != nil {
+			return nil, err
+		}
+		return []cdcChange{{table: key, record: deleteRecord(table, record)}}, nil
+	}
+
+	return nil, nil
+}
+
+// decodeTuple decodes the text encoded columns of a tuple and returns the columns left out as unchanged TOAST
+func (c *cdcService) decodeTuple(key string, relation *pglogrepl.RelationMessage, tuple *pglogrepl.TupleData) (map[string]any, []string, error) {
+	record := make(map[string]any)
+	var unchangedToast []string
+
+	if tuple == nil {
+		return record, nil, nil
+	}
+
+	for idx, column := range tuple.Columns {
+		name := relation.Columns[idx].Name
+		// The columns left out of the table by its projection or transforms are never loaded
+		if _, ok := c.columnMeta[key][name]; !ok {
+			continue
+		}
+
+		switch column.DataType {
+		case pglogrepl.TupleDataTypeNull:
+			record[name] = nil
+		case pglogrepl.TupleDataTypeToast:
+			unchangedToast = append(unchangedToast, name)
+		case pglogrepl.TupleDataTypeText:
+			value, err := c.decodeText(column.Data, relation.Columns[idx].DataType)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decode column %s of %s: %w", name, key, err)
+			}
+			record[name] = c.source.repo.ConvertValue(c.columnMeta[key], name, value)
+		}
+	}
+
+	return record, unchangedToast, nil
+}
+
+func (c *cdcService) decodeText(data []byte, dataType uint32) (any, error) {
+	if pgType, ok := c.typeMap.TypeForOID(dataType); ok {
+		return pgType.Codec.DecodeValue(c.typeMap, dataType, pgtype.TextFormatCode, data)
+	}
+	return string(data), nil
+}
+
+// flush loads the buffered transactions into the sink, then persists and confirms their end LSN
+func (c *cdcService) flush(ctx context.Context, conn *pgconn.PgConn) error {
+	if c.batch.count == 0 {
+		return nil
+	}
+
+	startTime := time.Now()
+	tableKeys := lo.Keys(c.batch.records)
+	sort.Strings(tableKeys)
+
+	for _, key := range tableKeys {
+		records, err := TransformService.Apply(c.tables[key], c.batch.records[key])
+		if err != nil {
+			return fmt.Errorf("failed to transform changes of %s up to LSN %s: %w", key, c.batch.endLSN, err)
+		}
+
+		// Labels are derived from the LSN, the sink skips a run already loaded before a crash
+		for run, records := range changeRuns(records) {
+			label := cdcLabel(c.cdcConfig.SlotName, key, c.batch.endLSN, run)
+			if _, err := c.sink.WriteBatch(ctx, c.tables[key], records, label); err != nil {
+				return fmt.Errorf("failed to load %d changes of %s up to LSN %s: %w", len(records), key, c.batch.endLSN, err)
+			}
+		}
+	}
+
+	// Only advance once every table of the batch is loaded, a restart replays from the previous LSN
+	if err := CheckpointService.SetConfirmedLSN(c.cdcConfig.SlotName, c.batch.endLSN.String()); err != nil {
+		return fmt.Errorf("failed to persist confirmed LSN: %w", err)
+	}
+
+	logger.Sugar.Infof("Flushed %d changes of %d tables up to LSN %s in %s", c.batch.count, len(tableKeys), c.batch.endLSN, time.Since(startTime))
+
+	c.confirmedLSN = c.batch.endLSN
+	c.ackLSN = c.batch.endLSN
+	c.batch = newCdcBatch()
+
+	return c.sendStatus(ctx, conn)
+}
+
+// sendStatus reports the acknowledged position so the server can release WAL
+func (c *cdcService) sendStatus(ctx context.Context, conn *pgconn.PgConn) error {
+	err := pglogrepl.SendStandbyStatusUpdate(ctx, conn, pglogrepl.StandbyStatusUpdate{WALWritePosition: c.ackLSN})
+	if err != nil {
+		return fmt.Errorf("failed to send standby status update: %w", err)
+	}
+	return nil
+}
+
+// shutdown flushes the committed transactions still buffered before stopping
+func (c *cdcService) shutdown(ctx context.Context, conn *pgconn.PgConn) error {
+	logger.Sugar.Info("Stopping change data capture, flushing buffered changes")
+
+	if err := c.flush(context.WithoutCancel(ctx), conn); err != nil {
+		return err
+	}
+
+	logger.Sugar.Infof("Change data capture stopped at LSN %s", c.confirmedLSN)
+	return nil
+}
+
+// cdcLabel derives the Stream Load label of a run of a flushed batch from the slot, table, end LSN and run
+func cdcLabel(slotName string, table string, endLSN pglogrepl.LSN, run int) string {
+	label := fmt.Sprintf("cdc_%s_%s_%016x_%d", slotName, table, uint64(endLSN), run)
+	label = labelUnsafeCharacters.ReplaceAllString(label, "_")
+	if len(label) > 128 {
+		label = label[len(label)-128:]
+	}
+	return label
+}
+
+// deleteRecord keeps the key of a deleted row, the other columns are NULL in the old tuple of REPLICA IDENTITY
+// DEFAULT tables and must not overwrite the row before Doris deletes it
+func deleteRecord(table dtos.TableInfo, record map[string]any) map[string]any {
+	deleted := make(map[string]any, len(table.PrimaryKeys)+1)
+	for _, primaryKey := range table.PrimaryKeys {
+		deleted[primaryKey.ColumnName] = record[primaryKey.ColumnName]
+	}
+	deleted[doris.DeleteSignColumn] = 1
+	return deleted
+}
+
+// changeRuns splits the changes of a table into consecutive runs of upserts and of deletes, loaded in order
+// since deletes are sent with their key columns only
+func changeRuns(records []map[string]any) [][]map[string]any {
+	var runs [][]map[string]any
+	for i, record := range records {
+		if i == 0 || isDeleteRecord(record) != isDeleteRecord(records[i-1]) {
+			runs = append(runs, nil)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], record)
+	}
+	return runs
+}
+
+// isDeleteRecord reports whether change data capture deletes the row of the record
+func isDeleteRecord(record map[string]any) bool {
+	return record[doris.DeleteSignColumn] == 1
+}
+
+func samePrimaryKey(table dtos.TableInfo, oldRecord map[string]any, newRecord map[string]any) bool {
+	for _, primaryKey := range table.PrimaryKeys {
+		if fmt.Sprint(oldRecord[primaryKey.ColumnName]) != fmt.Sprint(newRecord[primaryKey.ColumnName]) {
+			return false
+		}
+	}
+	return true
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
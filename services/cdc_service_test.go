@@ -0,0 +1,125 @@
+package services
+
+import (
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/destinations/doris"
+	"migration-tool-go/dtos/sources/postgres"
+	"migration-tool-go/repository"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// newTestCdcService captures public.orders, keyed by id, with the relation pgoutput sends for it
+func newTestCdcService(t *testing.T) *cdcService {
+	t.Helper()
+	converters, err := repository.NewConverters(postgres.ConversionConfiguration{})
+	if err != nil {
+		t.Fatalf("NewConverters: %v", err)
+	}
+
+	columns := []dtos.ColumnInfo{
+		{Name: "id", DataType: "int4", IsNullable: false},
+		{Name: "status", DataType: "text", IsNullable: false},
+		{Name: "note", DataType: "text", IsNullable: true},
+	}
+	table := dtos.TableInfo{TableSchema: "public", TableName: "orders", Columns: columns, PrimaryKeys: []dtos.PrimaryKey{{ColumnName: "id", DataType: "int4"}}}
+
+	service := &cdcService{
+		source:      &postgresMigration{repo: repository.NewRepo(nil, converters)},
+		tables:      map[string]dtos.TableInfo{"public.orders": table},
+		columnMeta:  map[string]map[string]dtos.ColumnInfo{"public.orders": {}},
+		relations:   make(map[uint32]*pglogrepl.RelationMessage),
+		typeMap:     pgtype.NewMap(),
+		toastWarned: make(map[string]bool),
+	}
+	for _, column := range columns {
+		service.columnMeta["public.orders"][column.Name] = column
+	}
+	service.relations[16384] = &pglogrepl.RelationMessage{
+		RelationID:   16384,
+		Namespace:    "public",
+		RelationName: "orders",
+		Columns: []*pglogrepl.RelationMessageColumn{
+			{Flags: 1, Name: "id", DataType: pgtype.Int4OID},
+			{Name: "status", DataType: pgtype.TextOID},
+			{Name: "note", DataType: pgtype.TextOID},
+		},
+	}
+	return service
+}
+
+func textColumn(value string) *pglogrepl.TupleDataColumn {
+	return &pglogrepl.TupleDataColumn{DataType: pglogrepl.TupleDataTypeText, Data: []byte(value)}
+}
+
+func nullColumn() *pglogrepl.TupleDataColumn {
+	return &pglogrepl.TupleDataColumn{DataType: pglogrepl.TupleDataTypeNull}
+}
+
+func TestDecodeChangeDeletesByKey(t *testing.T) {
+	service := newTestCdcService(t)
+	want := map[string]any{"id": int32(7), doris.DeleteSignColumn: 1}
+
+	cases := []struct {
+		name    string
+		message pglogrepl.Message
+		want    []map[string]any
+	}{
+		{
+			// REPLICA IDENTITY DEFAULT sends the key of a deleted row, its other columns are NULL
+			name: "delete under replica identity default",
+			message: &pglogrepl.DeleteMessage{RelationID: 16384, OldTupleType: pglogrepl.UpdateMessageTupleTypeKey, OldTuple: &pglogrepl.TupleData{
+				Columns: []*pglogrepl.TupleDataColumn{textColumn("7"), nullColumn(), nullColumn()},
+			}},
+			want: []map[string]any{want},
+		},
+		{
+			name: "delete under replica identity full",
+			message: &pglogrepl.DeleteMessage{RelationID: 16384, OldTupleType: pglogrepl.UpdateMessageTupleTypeOld, OldTuple: &pglogrepl.TupleData{
+				Columns: []*pglogrepl.TupleDataColumn{textColumn("7"), textColumn("shipped"), textColumn("fragile")},
+			}},
+			want: []map[string]any{want},
+		},
+		{
+			name: "update of the key",
+			message: &pglogrepl.UpdateMessage{RelationID: 16384, OldTupleType: pglogrepl.UpdateMessageTupleTypeKey,
+				OldTuple: &pglogrepl.TupleData{Columns: []*pglogrepl.TupleDataColumn{textColumn("7"), nullColumn(), nullColumn()}},
+				NewTuple: &pglogrepl.TupleData{Columns: []*pglogrepl.TupleDataColumn{textColumn("8"), textColumn("shipped"), nullColumn()}},
+			},
+			want: []map[string]any{want, {"id": int32(8), "status": "shipped", "note": nil, doris.DeleteSignColumn: 0}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := service.decodeChange(tc.message)
+			if err != nil {
+				t.Fatalf("decodeChange: %v", err)
+			}
+			var got []map[string]any
+			for _, change := range changes {
+				got = append(got, change.record)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("decodeChange = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChangeRuns(t *testing.T) {
+	upsert := func(id int) map[string]any { return map[string]any{"id": id, doris.DeleteSignColumn: 0} }
+	deleted := func(id int) map[string]any { return map[string]any{"id": id, doris.DeleteSignColumn: 1} }
+
+	got := changeRuns([]map[string]any{upsert(1), upsert(2), deleted(1), deleted(3), upsert(1)})
+	want := [][]map[string]any{{upsert(1), upsert(2)}, {deleted(1), deleted(3)}, {upsert(1)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changeRuns = %v, want %v", got, want)
+	}
+	if runs := changeRuns(nil); len(runs) != 0 {
+		t.Errorf("changeRuns(nil) = %v, want no runs", runs)
+	}
+}
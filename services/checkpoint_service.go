@@ -0,0 +1,276 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/common"
+	"migration-tool-go/logger"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// checkpointService persists the per table progress of a migration to a local state file
+type checkpointService struct {
+	mu        sync.Mutex
+	stateFile string
+	resume    bool
+	state     dtos.MigrationState
+}
+
+// CheckpointService is the global checkpoint service instance
+var CheckpointService *checkpointService
+
+// NewCheckpointService loads the state file. Unless resuming, the key progress of every table is reset
+// while the incremental watermarks are kept, and a new run id is started.
+func NewCheckpointService(config common.TackingConfiguration, resume bool) error {
+	service := &checkpointService{
+		stateFile: config.GetStateFile(),
+		resume:    resume,
+		state:     dtos.MigrationState{Tables: make(map[string]*dtos.TableCheckpoint)},
+	}
+
+	if err := service.load(); err != nil {
+		return err
+	}
+
+	if !resume {
+		for _, checkpoint := range service.state.Tables {
+			checkpoint.Status = ""
+			checkpoint.LastId = nil
+			checkpoint.LastIds = nil
+			checkpoint.KeyStrategy = ""
+			checkpoint.PendingWatermark = nil
+		}
+	}
+	if !resume || service.state.RunId == "" {
+		service.state.RunId = fmt.Sprintf("%s_%s", time.Now().UTC().Format("20060102T150405"), uuid.New().String()[:8])
+	}
+
+	CheckpointService = service
+
+	logger.Sugar.Infof("Checkpoint service initialized with state file=%s, resume=%v, run id=%s, tables in state=%d",
+		service.stateFile,
+		resume,
+		service.state.RunId,
+		len(service.state.Tables))
+
+	return nil
+}
+
+// RunId returns the id of the run, kept in the state file so a resumed run labels its loads like the interrupted one
+func (c *checkpointService) RunId() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state.RunId
+}
+
+// Get returns the checkpoint of a table, ok is false when the table has no checkpoint
+func (c *checkpointService) Get(schema string, table string) (dtos.TableCheckpoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint, ok := c.state.Tables[checkpointKey(schema, table)]
+	if !ok {
+		return dtos.TableCheckpoint{}, false
+	}
+	return *checkpoint, true
+}
+
+// IsCompleted reports whether the table was fully migrated by a previous run being resumed
+func (c *checkpointService) IsCompleted(schema string, table string) bool {
+	if !c.resume {
+		return false
+	}
+	checkpoint, ok := c.Get(schema, table)
+	return ok && checkpoint.Status == dtos.TableStatusCompleted
+}
+
+// CommitRange records the end of a fully loaded key range as the resume point of the table
+func (c *checkpointService) CommitRange(schema string, table string, keyRange dtos.PrimaryKeyRange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint := c.tableCheckpoint(schema, table)
+	checkpoint.Status = dtos.TableStatusInProgress
+
+	switch keyRange.Type {
+	case "id_range":
+		checkpoint.LastId = checkpointValue(keyRange.IdRange[1])
+	case "multi_key":
+		lastIds := make(map[string]any, len(keyRange.MultiKeyRange[1]))
+		for column, value := range keyRange.MultiKeyRange[1] {
+			lastIds[column] = checkpointValue(value)
+		}
+		checkpoint.LastIds = lastIds
+	case "ctid_range":
+		// The last range of a ctid scan is open ended, the table is completed after it
+		if keyRange.IdRange[1] != nil {
+			checkpoint.LastId = keyRange.IdRange[1]
+		}
+	}
+
+	return c.save()
+}
+
+// SetKeyStrategy records the key strategy a table is being extracted with
+func (c *checkpointService) SetKeyStrategy(schema string, table string, strategy string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint := c.tableCheckpoint(schema, table)
+	checkpoint.KeyStrategy = strategy
+
+	return c.save()
+}
+
+// SetPendingWatermark records the upper bound of the incremental window being synced
+func (c *checkpointService) SetPendingWatermark(schema string, table string, watermark any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint := c.tableCheckpoint(schema, table)
+	checkpoint.PendingWatermark = checkpointValue(watermark)
+
+	return c.save()
+}
+
+// MarkCompleted records that every record of the table has been loaded and promotes its pending watermark
+func (c *checkpointService) MarkCompleted(schema string, table string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint := c.tableCheckpoint(schema, table)
+	checkpoint.Status = dtos.TableStatusCompleted
+	if checkpoint.PendingWatermark != nil {
+		checkpoint.Watermark = checkpoint.PendingWatermark
+		checkpoint.PendingWatermark = nil
+	}
+
+	return c.save()
+}
+
+func (c *checkpointService) tableCheckpoint(schema string, table string) *dtos.TableCheckpoint {
+	key := checkpointKey(schema, table)
+	checkpoint, ok := c.state.Tables[key]
+	if !ok {
+		checkpoint = &dtos.TableCheckpoint{Schema: schema, Table: table}
+		c.state.Tables[key] = checkpoint
+	}
+	checkpoint.UpdatedAt = time.Now()
+	return checkpoint
+}
+
+// GetConfirmedLSN returns the last LSN loaded into the destination for a replication slot
+func (c *checkpointService) GetConfirmedLSN(slotName string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint, ok := c.state.Replication[slotName]
+	if !ok || checkpoint.ConfirmedLSN == "" {
+		return "", false
+	}
+	return checkpoint.ConfirmedLSN, true
+}
+
+// SetConfirmedLSN records the LSN up to which the changes of a replication slot have been loaded
+func (c *checkpointService) SetConfirmedLSN(slotName string, lsn string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state.Replication == nil {
+		c.state.Replication = make(map[string]*dtos.ReplicationCheckpoint)
+	}
+	c.state.Replication[slotName] = &dtos.ReplicationCheckpoint{
+		SlotName:     slotName,
+		ConfirmedLSN: lsn,
+		UpdatedAt:    time.Now(),
+	}
+
+	return c.save()
+}
+
+// load reads the state file, a missing file is treated as an empty state
+func (c *checkpointService) load() error {
+	data, err := os.ReadFile(c.stateFile)
+	if os.IsNotExist(err) {
+		logger.Sugar.Infof("State file %s does not exist, starting from scratch", c.stateFile)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c.state); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", c.stateFile, err)
+	}
+
+	if c.state.Tables == nil {
+		c.state.Tables = make(map[string]*dtos.TableCheckpoint)
+	}
+
+	// Keys are bound as text parameters, postgres casts them back to the column type
+	for _, checkpoint := range c.state.Tables {
+		checkpoint.LastId = fromJSONNumber(checkpoint.LastId)
+		checkpoint.Watermark = fromJSONNumber(checkpoint.Watermark)
+		checkpoint.PendingWatermark = fromJSONNumber(checkpoint.PendingWatermark)
+		for column, value := range checkpoint.LastIds {
+			checkpoint.LastIds[column] = fromJSONNumber(value)
+		}
+	}
+
+	return nil
+}
+
+// save writes the state to a temporary file and renames it so a crash never leaves a torn state file
+func (c *checkpointService) save() error {
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.stateFile), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmpFile := c.stateFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return os.Rename(tmpFile, c.stateFile)
+}
+
+func checkpointKey(schema string, table string) string {
+	return schema + "." + table
+}
+
+// checkpointValue converts a key value scanned by pgx into a JSON friendly value
+func checkpointValue(value any) any {
+	switch v := value.(type) {
+	case *any:
+		if v == nil {
+			return nil
+		}
+		return checkpointValue(*v)
+	case [16]uint8:
+		return uuid.UUID(v).String()
+	default:
+		return v
+	}
+}
+
+func fromJSONNumber(value any) any {
+	if number, ok := value.(json.Number); ok {
+		return number.String()
+	}
+	return value
+}
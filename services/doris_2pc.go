@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/destinations/doris"
+	"migration-tool-go/logger"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// twoPhaseCommitTimeout bounds the commits and aborts of a table, they run on a context that outlives cancellation
+const twoPhaseCommitTimeout = 5 * time.Minute
+
+// precommittedLoad is a two phase commit load waiting for its commit or abort.
+// The TxnId is unknown when the load was recovered from its label after a lost response.
+type precommittedLoad struct {
+	txnId int64
+	label string
+}
+
+// tableTransactions are the precommitted loads of a table and the Doris table they go into
+type tableTransactions struct {
+	target doris.TableTarget
+	loads  []precommittedLoad
+}
+
+// twoPhaseTransactions tracks the precommitted loads of the tables loaded with two phase commit
+type twoPhaseTransactions struct {
+	mu sync.Mutex
+	// tables is keyed by schema.table, a table is present from PrepareTable to FinalizeTable
+	tables map[string]*tableTransactions
+}
+
+func newTwoPhaseTransactions() *twoPhaseTransactions {
+	return &twoPhaseTransactions{tables: make(map[string]*tableTransactions)}
+}
+
+// open starts collecting the loads of a table
+func (t *twoPhaseTransactions) open(table dtos.TableInfo, target doris.TableTarget) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tables[tableKey(table)] = &tableTransactions{target: target}
+}
+
+// active reports whether the loads of a table are sent with two phase commit
+func (t *twoPhaseTransactions) active(table dtos.TableInfo) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return lo.HasKey(t.tables, tableKey(table))
+}
+
+func (t *twoPhaseTransactions) add(table dtos.TableInfo, load precommittedLoad) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if transactions, ok := t.tables[tableKey(table)]; ok {
+		transactions.loads = append(transactions.loads, load)
+	}
+}
+
+// take stops collecting the loads of a table and returns them
+func (t *twoPhaseTransactions) take(key string) (*tableTransactions, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	transactions, ok := t.tables[key]
+	delete(t.tables, key)
+	return transactions, ok
+}
+
+// keys returns the tables whose loads are still pending
+func (t *twoPhaseTransactions) keys() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return lo.Keys(t.tables)
+}
+
+func tableKey(table dtos.TableInfo) string {
+	return table.TableSchema + "." + table.TableName
+}
+
+// CommitsOnFinalize reports whether the loads of a table only become visible once FinalizeTable succeeds
+func (d dorisSyncService) CommitsOnFinalize(table dtos.TableInfo) bool {
+	return d.configuration.UsesTwoPhaseCommit(table.TableSchema, table.TableName)
+}
+
+// finishTransactions commits or aborts every precommitted load of a table
+func (d dorisSyncService) finishTransactions(ctx context.Context, key string, operation string) error {
+	transactions, ok := d.transactions.take(key)
+	if !ok || len(transactions.loads) == 0 {
+		return nil
+	}
+	loads := transactions.loads
+
+	// Aborts must go through when the run was cancelled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), twoPhaseCommitTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, load := range loads {
+		if err := d.twoPhaseCommit(ctx, transactions.target, load, operation); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to %s %d of the %d loads of %s: %w", operation, len(errs), len(loads), key, errors.Join(errs...))
+	}
+
+	logger.Sugar.Infof("Two phase commit: %s of the %d loads of %s succeeded", operation, len(loads), key)
+	return nil
+}
+
+// twoPhaseCommit commits or aborts a precommitted load through the first FE that answers
+func (d dorisSyncService) twoPhaseCommit(ctx context.Context, target doris.TableTarget, load precommittedLoad, operation string) error {
+	var errs []error
+	for range d.feNodes.size() {
+		node, err := d.feNodes.pick(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		err = d.twoPhaseCommitOnNode(ctx, node, target, load, operation)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errNodeUnavailable) {
+			return err
+		}
+		d.feNodes.eject(node, err)
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d dorisSyncService) twoPhaseCommitOnNode(ctx context.Context, node *dorisNode, target doris.TableTarget, load precommittedLoad, operation string) error {
+	commitUrl := fmt.Sprintf("http://%s/api/%s/%s/_stream_load_2pc", node.address, target.Database, target.Table)
+	transaction := "label " + load.label
+	if load.txnId > 0 {
+		transaction = fmt.Sprintf("txn %d", load.txnId)
+	}
+
+	resp, answeredBy, err := doFollowingRedirects(d.client, commitUrl, func(target string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("txn_operation", operation)
+		if load.txnId > 0 {
+			req.Header.Set("txn_id", strconv.FormatInt(load.txnId, 10))
+		} else {
+			req.Header.Set("label", load.label)
+		}
+		req.SetBasicAuth(d.connectionDetails.Username, d.connectionDetails.Password)
+		return req, nil
+	})
+	if err != nil && answeredBy == commitUrl && !errors.Is(err, errBadRedirect) {
+		return fmt.Errorf("%w: failed to send %s of %s to %s: %w", errNodeUnavailable, operation, transaction, node.address, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send %s of %s: %w", operation, transaction, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read response body from %s: %w", errNodeUnavailable, node.address, err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError && answeredBy == commitUrl {
+		return fmt.Errorf("%w: %s of %s on %s failed with status %s response %s", errNodeUnavailable, operation, transaction, node.address, resp.Status, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s of %s failed with status %s response %s", operation, transaction, resp.Status, string(body))
+	}
+
+	var response doris.TwoPhaseCommitResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to decode _stream_load_2pc response: %w, response %s", err, string(body))
+	}
+	if !strings.EqualFold(response.Status, doris.StreamLoadStatusSuccess) {
+		return fmt.Errorf("%s of %s failed with status %s: %s", operation, transaction, response.Status, response.Msg)
+	}
+	return nil
+}
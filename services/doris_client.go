@@ -0,0 +1,34 @@
+package services
+
+import (
+	"migration-tool-go/dtos/destinations/doris"
+	"net"
+	"net/http"
+	"time"
+)
+
+// newDorisHTTPClient creates the client shared by every request of the Doris sink. Connections are kept alive
+// and capped at the pool size per node. Redirects are returned to the caller, doFollowingRedirects follows
+// them with the credentials that net/http would drop.
+func newDorisHTTPClient(configuration doris.Configuration) *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   configuration.GetConnectTimeout(),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConnsPerHost:   configuration.GetPool(),
+		MaxConnsPerHost:       configuration.GetPool(),
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: configuration.GetResponseTimeout(),
+		// The payload waits for the 100 Continue of Doris, so a FE redirects a load before it is sent
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
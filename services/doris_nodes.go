@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"migration-tool-go/logger"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds the health check of an ejected node
+const healthCheckTimeout = 5 * time.Second
+
+var (
+	// errNodeUnavailable marks a request that failed because of the node it was sent to
+	errNodeUnavailable = errors.New("doris node unavailable")
+	// errNoHealthyNode is returned while every node of a pool is ejected
+	errNoHealthyNode = errors.New("no healthy doris node")
+)
+
+// dorisNode is the HTTP endpoint of a FE or BE
+type dorisNode struct {
+	address string
+	// ejectedUntil is zero while the node is healthy
+	ejectedUntil time.Time
+}
+
+// nodePool hands out the nodes of one kind round robin. A failed node is ejected and
+// only handed out again once its ejection expired and a health check succeeded.
+type nodePool struct {
+	kind     string
+	nodes    []*dorisNode
+	ejection time.Duration
+	client   *http.Client
+	next     int
+	mu       sync.Mutex
+}
+
+func newNodePool(kind string, addresses []string, ejection time.Duration, client *http.Client) (*nodePool, error) {
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no Doris %s node configured", kind)
+	}
+
+	pool := &nodePool{kind: kind, ejection: ejection, client: client}
+	for _, address := range addresses {
+		pool.nodes = append(pool.nodes, &dorisNode{address: address})
+	}
+	return pool, nil
+}
+
+// size returns the number of nodes of the pool
+func (p *nodePool) size() int {
+	return len(p.nodes)
+}
+
+// pick returns the next healthy node, ejected nodes whose ejection expired are health checked first
+func (p *nodePool) pick(ctx context.Context) (*dorisNode, error) {
+	p.mu.Lock()
+	start := p.next
+	p.next = (p.next + 1) % len(p.nodes)
+	p.mu.Unlock()
+
+	for i := range p.nodes {
+		node := p.nodes[(start+i)%len(p.nodes)]
+
+		p.mu.Lock()
+		ejectedUntil := node.ejectedUntil
+		expired := !ejectedUntil.IsZero() && !time.Now().Before(ejectedUntil)
+		if expired {
+			// Keep the node ejected while it is checked, so concurrent picks skip it
+			node.ejectedUntil = time.Now().Add(p.ejection)
+		}
+		p.mu.Unlock()
+
+		if ejectedUntil.IsZero() {
+			return node, nil
+		}
+		if !expired {
+			continue
+		}
+
+		if err := p.healthCheck(ctx, node); err != nil {
+			logger.Sugar.Warnf("Doris %s %s failed its health check, ejected for another %s: %v", p.kind, node.address, p.ejection, err)
+			continue
+		}
+		p.mu.Lock()
+		node.ejectedUntil = time.Time{}
+		p.mu.Unlock()
+		logger.Sugar.Infof("Doris %s %s passed its health check and is back in rotation", p.kind, node.address)
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("%w: all %d %s nodes are ejected", errNoHealthyNode, len(p.nodes), p.kind)
+}
+
+// eject skips the node until its ejection expires
+func (p *nodePool) eject(node *dorisNode, cause error) {
+	p.mu.Lock()
+	wasHealthy := node.ejectedUntil.IsZero()
+	node.ejectedUntil = time.Now().Add(p.ejection)
+	p.mu.Unlock()
+
+	if wasHealthy {
+		logger.Sugar.Warnf("Ejecting Doris %s %s for %s: %v", p.kind, node.address, p.ejection, cause)
+	}
+}
+
+// healthCheck calls the health API that FE and BE nodes both serve
+func (p *nodePool) healthCheck(ctx context.Context, node *dorisNode) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/api/health", node.address), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check answered status %s", resp.Status)
+	}
+	return nil
+}
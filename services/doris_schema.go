@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"migration-tool-go/config"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/destinations/doris"
+	"migration-tool-go/logger"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// dorisMaxVarcharLength is the largest VARCHAR length in bytes accepted by Doris
+	dorisMaxVarcharLength = 65533
+	// dorisMaxDecimalPrecision is the largest DECIMAL precision accepted by Doris
+	dorisMaxDecimalPrecision = 38
+	// utf8MaxBytesPerChar converts PostgreSQL character lengths to Doris byte lengths
+	utf8MaxBytesPerChar = 4
+)
+
+// schemaService generates the Doris tables matching the PostgreSQL source tables
+type schemaService struct {
+	source       *postgresMigration
+	sink         *dorisSyncService
+	schemaConfig doris.SchemaConfiguration
+}
+
+// NewSchemaService creates the schema service for a postgres source and a doris sink
+func NewSchemaService(source Source, sink Sink) (*schemaService, error) {
+	postgresSource, ok := source.(*postgresMigration)
+	if !ok {
+		return nil, fmt.Errorf("schema generation requires a postgres source, got %T", source)
+	}
+	dorisSink, ok := sink.(*dorisSyncService)
+	if !ok {
+		return nil, fmt.Errorf("schema generation requires a doris destination, got %T", sink)
+	}
+
+	schemaConfig := dorisSink.configuration.Schema
+	schemaConfig.SetDefaults()
+
+	return &schemaService{
+		source:       postgresSource,
+		sink:         dorisSink,
+		schemaConfig: schemaConfig,
+	}, nil
+}
+
+// Generate returns a CREATE DATABASE statement per target database followed by a CREATE TABLE statement per selected table.
+// Tables that cannot be mapped are reported in the returned error, the statements of the others are still returned.
+func (s *schemaService) Generate(ctx context.Context) ([]string, error) {
+	tables, err := s.source.discoverTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables = lo.Filter(tables, func(table dtos.TableInfo, _ int) bool {
+		if len(table.PrimaryKeys) == 0 {
+			logger.Sugar.Warnf("Skipping table %s.%s, it has no primary key nor NOT NULL unique index to build a Unique Key table from", table.TableSchema, table.TableName)
+			return false
+		}
+		return true
+	})
+
+	errs := []error{s.sink.targetCollisions(tables)}
+	var statements []string
+	databases := lo.Uniq(lo.Map(tables, func(table dtos.TableInfo, _ int) string { return s.sink.target(table).Database }))
+	for _, database := range databases {
+		statements = append(statements, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s;", quoteDorisIdentifier(database)))
+	}
+	for _, table := range tables {
+
+		statement, err := s.createTableStatement(table)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("table %s.%s: %w", table.TableSchema, table.TableName, err))
+			continue
+		}
+		statements = append(statements, statement)
+	}
+
+	return statements, errors.Join(errs...)
+}
+
+// Apply executes the statements through the MySQL protocol port of the FE
+func (s *schemaService) Apply(ctx context.Context, statements []string) error {
+	db, err := config.NewDorisQueryConnection(ctx, s.sink.connectionDetails)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to execute %q: %w", statement, err)
+		}
+		logger.Sugar.Infof("Executed: %s", strings.SplitN(statement, "\n", 2)[0])
+	}
+
+	return nil
+}
+
+// createTableStatement builds a Unique Key table whose key columns are the primary key, or the unique index, of the source table
+func (s *schemaService) createTableStatement(table dtos.TableInfo) (string, error) {
+	// Columns are created under the Doris name they are loaded into
+	if err := checkRename(table, s.sink.configuration.GetTableOptions(table.TableSchema, table.TableName)); err != nil {
+		return "", err
+	}
+	columnName := func(column string) string { return quoteDorisIdentifier(s.sink.columnName(table, column)) }
+
+	columns := lo.SliceToMap(table.Columns, func(column dtos.ColumnInfo) (string, dtos.ColumnInfo) {
+		return column.Name, column
+	})
+
+	// Doris requires the key columns to be the leading columns, in key order
+	var definitions []string
+	var keyNames []string
+	keyColumns := make(map[string]bool)
+	for _, key := range table.PrimaryKeys {
+		keyColumns[key.ColumnName] = true
+		column := columns[key.ColumnName]
+		columnType, err := dorisKeyColumnType(column)
+		if err != nil {
+			return "", err
+		}
+		definitions = append(definitions, fmt.Sprintf("  %s %s NOT NULL", columnName(column.Name), columnType))
+		keyNames = append(keyNames, columnName(column.Name))
+	}
+
+	for _, column := range loadableColumns(table) {
+		if keyColumns[column.Name] {
+			continue
+		}
+		column, _ = TransformService.ColumnType(table, column)
+		nullability := "NULL"
+		if !column.IsNullable {
+			nullability = "NOT NULL"
+		}
+		definitions = append(definitions, fmt.Sprintf("  %s %s %s", columnName(column.Name), dorisColumnType(column), nullability))
+	}
+
+	buckets := "AUTO"
+	if s.schemaConfig.Buckets > 0 {
+		buckets = fmt.Sprintf("%d", s.schemaConfig.Buckets)
+	}
+
+	var statement strings.Builder
+	target := s.sink.target(table)
+	fmt.Fprintf(&statement, "CREATE TABLE IF NOT EXISTS %s.%s (\n", quoteDorisIdentifier(target.Database), quoteDorisIdentifier(target.Table))
+	statement.WriteString(strings.Join(definitions, ",\n"))
+	statement.WriteString("\n)\n")
+	fmt.Fprintf(&statement, "UNIQUE KEY(%s)\n", strings.Join(keyNames, ", "))
+	fmt.Fprintf(&statement, "DISTRIBUTED BY HASH(%s) BUCKETS %s", strings.Join(keyNames, ", "), buckets)
+
+	tableProperties := s.tableProperties(table)
+	if len(tableProperties) > 0 {
+		properties := lo.Keys(tableProperties)
+		sort.Strings(properties)
+		statement.WriteString("\nPROPERTIES (\n")
+		statement.WriteString(strings.Join(lo.Map(properties, func(property string, _ int) string {
+			return fmt.Sprintf("  %s = %s", quoteDorisString(property), quoteDorisString(tableProperties[property]))
+		}), ",\n"))
+		statement.WriteString("\n)")
+	}
+	statement.WriteString(";")
+
+	return statement.String(), nil
+}
+
+// tableProperties returns the configured properties along with those the write options of the table require
+func (s *schemaService) tableProperties(table dtos.TableInfo) map[string]string {
+	properties := lo.Assign(s.schemaConfig.Properties)
+	options := s.sink.configuration.GetTableOptions(table.TableSchema, table.TableName)
+	if options.SequenceCol != "" {
+		properties["function_column.sequence_col"] = options.ColumnName(options.SequenceCol)
+	}
+	if options.PartialColumns && !lo.HasKey(properties, "enable_unique_key_merge_on_write") {
+		// Partial column updates are only supported by merge on write tables
+		properties["enable_unique_key_merge_on_write"] = "true"
+	}
+	return properties
+}
+
+// dorisKeyColumnType maps a primary key column, Doris does not accept floating point, JSON, STRING or ARRAY keys
+func dorisKeyColumnType(column dtos.ColumnInfo) (string, error) {
+	columnType := dorisColumnType(column)
+	switch {
+	case columnType == "STRING":
+		return fmt.Sprintf("VARCHAR(%d)", dorisMaxVarcharLength), nil
+	case columnType == "FLOAT", columnType == "DOUBLE", columnType == "JSON", strings.HasPrefix(columnType, "ARRAY"):
+		return "", fmt.Errorf("primary key column %s of type %s maps to %s which cannot be a Doris key", column.Name, column.DataType, columnType)
+	}
+	return columnType, nil
+}
+
+// dorisColumnType maps the udt_name of a PostgreSQL column to a Doris type.
+// Types without a lossless Doris counterpart are loaded as STRING.
+func dorisColumnType(column dtos.ColumnInfo) string {
+	// Array udt names are the element udt name prefixed with an underscore
+	if elementType, ok := strings.CutPrefix(column.DataType, "_"); ok {
+		element := dtos.ColumnInfo{Name: column.Name, DataType: elementType}
+		return fmt.Sprintf("ARRAY<%s>", dorisColumnType(element))
+	}
+
+	switch column.DataType {
+	case "bool":
+		return "BOOLEAN"
+	case "int2":
+		return "SMALLINT"
+	case "int4":
+		return "INT"
+	case "int8":
+		return "BIGINT"
+	case "float4":
+		return "FLOAT"
+	case "float8":
+		return "DOUBLE"
+	case "numeric":
+		// Unconstrained numerics can hold more digits than any Doris DECIMAL
+		if column.Precision.Valid && column.Precision.Int64 <= dorisMaxDecimalPrecision {
+			return fmt.Sprintf("DECIMAL(%d, %d)", column.Precision.Int64, column.Scale.Int64)
+		}
+		return "STRING"
+	case "date":
+		return "DATE"
+	case "timestamp", "timestamptz":
+		precision := int64(6)
+		if column.DatetimePrecision.Valid && column.DatetimePrecision.Int64 < precision {
+			precision = column.DatetimePrecision.Int64
+		}
+		return fmt.Sprintf("DATETIME(%d)", precision)
+	case "uuid":
+		return "VARCHAR(36)"
+	case "varchar", "bpchar":
+		if column.MaxLength.Valid && column.MaxLength.Int64*utf8MaxBytesPerChar <= dorisMaxVarcharLength {
+			return fmt.Sprintf("VARCHAR(%d)", column.MaxLength.Int64*utf8MaxBytesPerChar)
+		}
+		return "STRING"
+	case "json", "jsonb", "hstore":
+		// hstore values are loaded as JSON objects
+		return "JSON"
+	}
+
+	return "STRING"
+}
+
+// quoteDorisIdentifier quotes a database, table or column name
+func quoteDorisIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteDorisString quotes a string literal
+func quoteDorisString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
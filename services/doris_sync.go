@@ -1,80 +1,650 @@
 package services
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"migration-tool-go/config"
+	"migration-tool-go/dtos"
 	"migration-tool-go/dtos/common"
 	"migration-tool-go/dtos/destinations/doris"
 	"migration-tool-go/logger"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
 )
 
-var DorisSyncService = &dorisSyncService{}
+// ErrStreamLoadFailed is returned when Doris aborted a Stream Load, no row of the batch was loaded
+var ErrStreamLoadFailed = errors.New("stream load failed")
+
+// errLoadOutcomeUnknown marks a Stream Load whose request may have reached Doris without a conclusive answer
+var errLoadOutcomeUnknown = errors.New("stream load outcome unknown")
+
+// transientLoadFailures are fragments of the messages of aborted loads that succeed when sent again
+var transientLoadFailures = []string{
+	"timeout",
+	"timed out",
+	"too many versions",
+	"too many tasks",
+	"too many running",
+	"memory",
+	"connection",
+	"unavailable",
+	"try again",
+	"busy",
+}
+
+// maxRedirects bounds the redirects followed by a request, a FE redirects once to a BE
+const maxRedirects = 3
+
+// errBadRedirect is returned for redirects that cannot be followed, the request never reached a BE
+var errBadRedirect = errors.New("bad redirect")
 
 type dorisSyncService struct {
 	connectionDetails doris.ConnectionDetails
 	configuration     doris.Configuration
+	// loadNodes receive the Stream Loads, feNodes answer the label state checks
+	loadNodes *nodePool
+	feNodes   *nodePool
+	encoder   *loadEncoder
+	// transactions holds the precommitted loads of the tables loaded with two phase commit
+	transactions *twoPhaseTransactions
+	// targets holds the source table loaded into each Doris table, two never share one
+	targets *targetClaims
+	client  *http.Client
 }
 
-func NewDorisSync(destination common.Destination[any]) {
-	DorisSyncService = &dorisSyncService{
-		connectionDetails: destination.Value.(doris.Doris).ConnectionDetails,
-		configuration:     destination.Value.(doris.Doris).Configuration,
-	}
+func init() {
+	RegisterSink("doris", config.DecodeValue[doris.Doris], NewDorisSync)
 }
 
-func (d dorisSyncService) SyncDoris(jsonData []byte, noOfRecords uint64, table string, uniqueLabel string, checkAllRecordsProcessed map[string]uint64) error {
-	// Step 2: Send JSON data directly to Doris (No file involved)
-	dorisUrl := fmt.Sprintf("http://%s:%d/api/%s/%s/_stream_load", d.connectionDetails.BeNodes, d.connectionDetails.BePort, d.connectionDetails.Database, table)
-	username := d.connectionDetails.Username
-	password := d.connectionDetails.Password
-	err := d.StreamLoadDoris(dorisUrl, username, password, jsonData, uniqueLabel)
+// NewDorisSync creates the Doris sink
+func NewDorisSync(destination common.Destination[any]) (Sink, error) {
+	dorisDestination, ok := destination.Value.(doris.Doris)
+	if !ok {
+		return nil, fmt.Errorf("invalid doris destination configuration of type %T", destination.Value)
+	}
 
+	connectionDetails := dorisDestination.ConnectionDetails
+	configuration := dorisDestination.Configuration
+	configuration.Retry.SetDefaults()
+	configuration.Mapping.SetDefaults()
+	if err := configuration.Mapping.Validate(); err != nil {
+		return nil, err
+	}
+	for _, options := range configuration.Tables {
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	ejection := time.Duration(configuration.GetNodeEjectionMs()) * time.Millisecond
+
+	encoder, err := newLoadEncoder(configuration.Load)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newDorisHTTPClient(configuration)
+
+	feNodes, err := newNodePool("FE", connectionDetails.GetFeAddresses(), ejection, client)
 	if err != nil {
+		return nil, err
+	}
+
+	loadNodes := feNodes
+	switch routing := configuration.GetLoadRouting(connectionDetails); routing {
+	case doris.LoadRoutingFe:
+	case doris.LoadRoutingBe:
+		if loadNodes, err = newNodePool("BE", connectionDetails.GetBeAddresses(), ejection, client); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown doris load routing %q, expected %s or %s", routing, doris.LoadRoutingBe, doris.LoadRoutingFe)
+	}
+
+	return &dorisSyncService{
+		connectionDetails: connectionDetails,
+		configuration:     configuration,
+		loadNodes:         loadNodes,
+		feNodes:           feNodes,
+		encoder:           encoder,
+		transactions:      newTwoPhaseTransactions(),
+		targets:           newTargetClaims(),
+		client:            client,
+	}, nil
+}
+
+// PrepareTable checks the target and the write options of the table and starts collecting the precommitted loads
+// of tables loaded with two phase commit. Doris tables are expected to exist before loading.
+func (d dorisSyncService) PrepareTable(ctx context.Context, table dtos.TableInfo) error {
+	target := d.target(table)
+	if err := d.targets.claim(target, tableKey(table)); err != nil {
 		return err
 	}
+	if _, err := d.loadColumns(table, d.configuration.GetTableOptions(table.TableSchema, table.TableName), false); err != nil {
+		return err
+	}
+	if d.CommitsOnFinalize(table) {
+		logger.Sugar.Infof("Loading table %s with two phase commit, its rows become visible once the table completes", tableKey(table))
+		d.transactions.open(table, target)
+	}
+	return nil
+}
 
-	checkAllRecordsProcessed[uniqueLabel] = noOfRecords
+// WriteBatch serializes the records in the configured load format and stream loads them into the Doris table
+func (d dorisSyncService) WriteBatch(ctx context.Context, table dtos.TableInfo, records []map[string]any, uniqueLabel string) (uint64, error) {
+	if len(records) == 0 {
+		return 0, fmt.Errorf("no records to write")
+	}
+
+	// Checked on every batch, change data capture does not prepare its tables and the runner only logs a failed PrepareTable
+	if err := d.targets.claim(d.target(table), tableKey(table)); err != nil {
+		return 0, err
+	}
+	options := d.configuration.GetTableOptions(table.TableSchema, table.TableName)
+	_, deletes := records[0][doris.DeleteSignColumn]
+	// Change data capture sends a run of deletes with the key columns only, as a partial update of the delete sign
+	keyOnly := isDeleteRecord(records[0])
+	columns := deleteColumns(table)
+	if !keyOnly {
+		var err error
+		if columns, err = d.loadColumns(table, options, deletes); err != nil {
+			return 0, err
+		}
+	}
+	batch := loadBatch{records: records, columns: columns, explicitColumns: len(options.Columns) > 0 || keyOnly, rename: options.Rename}
+
+	headers := d.encoder.Headers(batch)
+	for key, value := range writeOptionHeaders(options, deletes) {
+		headers[key] = value
+	}
+	if keyOnly {
+		headers["partial_columns"] = "true"
+	}
+	twoPhaseCommit := d.transactions.active(table)
+	if twoPhaseCommit {
+		headers["two_phase_commit"] = "true"
+	}
+
+	result, err := d.streamLoadWithRetry(ctx, table, d.encoder.Payload(batch), uniqueLabel, headers, len(records))
+	if err != nil {
+		return 0, err
+	}
+	if twoPhaseCommit {
+		// Batches with rejected rows are precommitted too, the table then fails and aborts them all
+		d.transactions.add(table, precommittedLoad{txnId: result.TxnId, label: uniqueLabel})
+	}
+
+	return loadedRows(result, len(records), uniqueLabel)
+}
+
+// loadedRows returns the rows a committed load loaded, along with ErrRowsRejected when Doris did not read
+// every row sent or filtered some of them
+func loadedRows(result doris.StreamLoadResult, rows int, uniqueLabel string) (uint64, error) {
+	if result.NumberTotalRows != int64(rows) {
+		return uint64(result.NumberLoadedRows), fmt.Errorf("%w: doris read %d rows of the %d sent for label %s", ErrRowsRejected, result.NumberTotalRows, rows, uniqueLabel)
+	}
+	if result.NumberFilteredRows > 0 {
+		return uint64(result.NumberLoadedRows), fmt.Errorf("%w: %d of %d rows filtered for label %s, see %s", ErrRowsRejected, result.NumberFilteredRows, rows, uniqueLabel, result.ErrorURL)
+	}
+
+	return uint64(result.NumberLoadedRows), nil
+}
+
+// loadColumns returns the columns a batch loads, the configured columns or else every column of the source table
+// and its derived columns, followed by the delete sign when change data capture deletes rows
+func (d dorisSyncService) loadColumns(table dtos.TableInfo, options doris.TableOptions, deletes bool) ([]dtos.ColumnInfo, error) {
+	columns := loadableColumns(table)
+	if len(options.Columns) > 0 {
+		sourceColumns := lo.SliceToMap(loadableColumns(table), func(column dtos.ColumnInfo) (string, dtos.ColumnInfo) {
+			return column.Name, column
+		})
+		columns = make([]dtos.ColumnInfo, 0, len(options.Columns)+1)
+		for _, name := range options.Columns {
+			column, ok := sourceColumns[name]
+			if !ok {
+				return nil, fmt.Errorf("table %s: configured column %s is not a column of the source table", tableKey(table), name)
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	if options.PartialColumns {
+		// A partial update finds the rows it updates by their key
+		for _, key := range table.PrimaryKeys {
+			if !lo.ContainsBy(columns, func(column dtos.ColumnInfo) bool { return column.Name == key.ColumnName }) {
+				return nil, fmt.Errorf("table %s: partial_columns requires key column %s among the columns", tableKey(table), key.ColumnName)
+			}
+		}
+	}
+	if options.SequenceCol != "" && !lo.ContainsBy(columns, func(column dtos.ColumnInfo) bool { return column.Name == options.SequenceCol }) {
+		return nil, fmt.Errorf("table %s: sequence_col %s is not a loaded column", tableKey(table), options.SequenceCol)
+	}
+	if err := checkRename(table, options); err != nil {
+		return nil, err
+	}
+
+	if deletes {
+		columns = append(append([]dtos.ColumnInfo{}, columns...), dtos.ColumnInfo{Name: doris.DeleteSignColumn, DataType: "int4"})
+	}
+	return columns, nil
+}
+
+// deleteColumns returns the columns of a run of change data capture deletes, the key columns and the delete sign
+func deleteColumns(table dtos.TableInfo) []dtos.ColumnInfo {
+	columns := lo.Map(table.PrimaryKeys, func(key dtos.PrimaryKey, _ int) dtos.ColumnInfo {
+		return dtos.ColumnInfo{Name: key.ColumnName, DataType: key.DataType}
+	})
+	return append(columns, dtos.ColumnInfo{Name: doris.DeleteSignColumn, DataType: "int4"})
+}
+
+// loadableColumns returns the extracted columns of a table followed by the columns derived from them
+func loadableColumns(table dtos.TableInfo) []dtos.ColumnInfo {
+	return append(append([]dtos.ColumnInfo{}, table.Columns...), TransformService.DerivedColumns(table)...)
+}
+
+// checkRename checks that the renamed columns exist in the source table and that no two columns are loaded
+// into the same Doris column
+func checkRename(table dtos.TableInfo, options doris.TableOptions) error {
+	columns := loadableColumns(table)
+	for column := range options.Rename {
+		if !lo.ContainsBy(columns, func(sourceColumn dtos.ColumnInfo) bool { return sourceColumn.Name == column }) {
+			return fmt.Errorf("table %s: renamed column %s is not a column of the source table", tableKey(table), column)
+		}
+	}
+
+	names := make(map[string]string, len(columns))
+	for _, column := range columns {
+		name := options.ColumnName(column.Name)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("table %s: columns %s and %s are both loaded into Doris column %s", tableKey(table), other, column.Name, name)
+		}
+		names[name] = column.Name
+	}
 	return nil
 }
 
-// StreamLoadDoris uploads JSON data directly to Apache Doris
-func (d dorisSyncService) StreamLoadDoris(dorisURL, username, password string, jsonData []byte, uniqueLabel string) error {
-	// Create HTTP request
-	req, err := http.NewRequest("PUT", dorisURL, bytes.NewReader(jsonData))
+// columnName returns the Doris column a source column of the table is loaded into
+func (d dorisSyncService) columnName(table dtos.TableInfo, column string) string {
+	return d.configuration.GetTableOptions(table.TableSchema, table.TableName).ColumnName(column)
+}
+
+// writeOptionHeaders returns the Stream Load headers of the write options of a table
+func writeOptionHeaders(options doris.TableOptions, deletes bool) map[string]string {
+	headers := make(map[string]string)
+	if options.PartialColumns {
+		headers["partial_columns"] = "true"
+	}
+	if options.SequenceCol != "" {
+		headers["function_column.sequence_col"] = options.ColumnName(options.SequenceCol)
+	}
+	// Change data capture batches carry their deletes in the delete sign, which a merge type would override
+	if options.MergeType != "" && !deletes {
+		headers["merge_type"] = strings.ToUpper(options.MergeType)
+		if options.Delete != "" {
+			headers["delete"] = options.Delete
+		}
+	}
+	return headers
+}
+
+// streamLoadWithRetry sends a batch until it is loaded, the attempts are exhausted or it fails for good.
+// Every attempt and a resumed run reuse the label, so Doris refuses to load the rows twice. When an attempt ends without
+// a conclusive answer the label state is read from the FE before sending the rows again.
+func (d dorisSyncService) streamLoadWithRetry(ctx context.Context, table dtos.TableInfo, payload func() io.ReadCloser, label string, headers map[string]string, rows int) (doris.StreamLoadResult, error) {
+	retry := d.configuration.Retry
+
+	for attempt := 1; ; attempt++ {
+		result, err := d.streamLoadToNode(ctx, table, payload, label, headers)
+		if err == nil {
+			return result, nil
+		}
+		// Only an earlier attempt of this batch, or the interrupted run being resumed, can have used the label
+		if errors.Is(err, ErrLabelAlreadyExists) {
+			logger.Sugar.Infof("Stream Load label %s was loaded by an earlier attempt or run", label)
+			return committedLoadResult(label, rows), nil
+		}
+		if attempt >= retry.MaxAttempts || !retryableLoadError(err) {
+			return result, err
+		}
+
+		for check := 1; ; check++ {
+			delay := loadBackoff(retry, attempt+check-1)
+			logger.Sugar.Warnf("Stream Load attempt %d/%d for label %s failed, retrying in %s: %v", attempt, retry.MaxAttempts, label, delay, err)
+			if err := sleepContext(ctx, delay); err != nil {
+				return result, err
+			}
+			if !errors.Is(err, errLoadOutcomeUnknown) {
+				break
+			}
+
+			state, stateErr := d.GetLoadState(ctx, d.target(table).Database, label)
+			if stateErr != nil {
+				// Sending again is safe, Doris refuses a label that is already committed
+				logger.Sugar.Warnf("Failed to read the state of label %s, sending the batch again: %v", label, stateErr)
+				break
+			}
+			if state == doris.LoadStateCommitted || state == doris.LoadStateVisible || state == doris.LoadStatePrecommitted {
+				logger.Sugar.Infof("Stream Load label %s is %s, the batch was loaded by attempt %d", label, state, attempt)
+				return committedLoadResult(label, rows), nil
+			}
+			if state != doris.LoadStatePrepare {
+				break
+			}
+
+			// The load is still running, wait for its outcome instead of sending the rows again
+			if check >= retry.MaxAttempts {
+				return result, fmt.Errorf("stream load label %s is still %s after %d checks: %w", label, state, check, err)
+			}
+		}
+	}
+}
+
+// streamLoadToNode sends a batch to the next healthy load node, the node is ejected when the request fails because of it
+func (d dorisSyncService) streamLoadToNode(ctx context.Context, table dtos.TableInfo, payload func() io.ReadCloser, label string, headers map[string]string) (doris.StreamLoadResult, error) {
+	node, err := d.loadNodes.pick(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return doris.StreamLoadResult{}, err
+	}
+
+	target := d.target(table)
+	dorisUrl := fmt.Sprintf("http://%s/api/%s/%s/_stream_load", node.address, target.Database, target.Table)
+	result, err := d.StreamLoadDoris(ctx, dorisUrl, d.connectionDetails.Username, d.connectionDetails.Password, payload, label, headers)
+	if errors.Is(err, errNodeUnavailable) {
+		d.loadNodes.eject(node, err)
+	}
+	return result, err
+}
+
+// GetLoadState reads the state of a Stream Load label of a database through the first FE that answers
+func (d dorisSyncService) GetLoadState(ctx context.Context, database string, label string) (string, error) {
+	var errs []error
+	for range d.feNodes.size() {
+		node, err := d.feNodes.pick(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		state, err := d.getLoadStateFromNode(ctx, node, database, label)
+		if err == nil {
+			return state, nil
+		}
+		if !errors.Is(err, errNodeUnavailable) {
+			return "", err
+		}
+		d.feNodes.eject(node, err)
+		errs = append(errs, err)
 	}
 
-	// Set Stream Load headers
-	req.Header.Set("Expect", "100-continue")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("format", "json")            // Specify JSON format
-	req.Header.Set("strip_outer_array", "true") // Required for JSON array input
-	req.Header.Set("label", uniqueLabel)        // Unique label
-	req.SetBasicAuth(username, password)
+	return "", errors.Join(errs...)
+}
 
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (d dorisSyncService) getLoadStateFromNode(ctx context.Context, node *dorisNode, database string, label string) (string, error) {
+	stateUrl := fmt.Sprintf("http://%s/api/%s/get_load_state?label=%s", node.address, database, url.QueryEscape(label))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, stateUrl, nil)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(d.connectionDetails.Username, d.connectionDetails.Password)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to send request to %s: %w", errNodeUnavailable, node.address, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read response body from %s: %w", errNodeUnavailable, node.address, err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", fmt.Errorf("%w: get_load_state on %s failed with status %s response %s", errNodeUnavailable, node.address, resp.Status, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get_load_state failed with status %s response %s", resp.Status, string(body))
+	}
+
+	var response doris.LoadStateResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to decode get_load_state response: %w, response %s", err, string(body))
+	}
+	if response.Code != 0 {
+		return "", fmt.Errorf("get_load_state failed with code %d: %s", response.Code, response.Msg)
+	}
+
+	return response.Data, nil
+}
+
+// retryableLoadError reports whether a batch may succeed when sent again
+func retryableLoadError(err error) bool {
+	if errors.Is(err, errLoadOutcomeUnknown) || errors.Is(err, errNoHealthyNode) {
+		return true
+	}
+	if errors.Is(err, ErrStreamLoadFailed) {
+		message := strings.ToLower(err.Error())
+		for _, fragment := range transientLoadFailures {
+			if strings.Contains(message, fragment) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadBackoff returns the exponential delay before the next attempt with jitter over its upper half
+func loadBackoff(retry doris.RetryConfiguration, attempt int) time.Duration {
+	delay := time.Duration(retry.InitialBackoffMs) * time.Millisecond
+	maxDelay := time.Duration(retry.MaxBackoffMs) * time.Millisecond
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleepContext waits for the delay unless the context is cancelled first
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// committedLoadResult stands for a load committed by an earlier attempt or run whose response was lost.
+// Loads are committed only when no row is filtered, so every row of the batch is counted as loaded.
+func committedLoadResult(label string, rows int) doris.StreamLoadResult {
+	return doris.StreamLoadResult{
+		Label:            label,
+		Status:           doris.StreamLoadStatusSuccess,
+		NumberTotalRows:  int64(rows),
+		NumberLoadedRows: int64(rows),
+	}
+}
+
+// target returns the Doris database and table a source table is loaded into
+func (d dorisSyncService) target(table dtos.TableInfo) doris.TableTarget {
+	return d.configuration.GetTarget(d.connectionDetails.Database, table.TableSchema, table.TableName)
+}
+
+// targetCollisions reports the Doris tables that more than one of the source tables map to
+func (d dorisSyncService) targetCollisions(tables []dtos.TableInfo) error {
+	claims := newTargetClaims()
+	var errs []error
+	for _, table := range tables {
+		if err := claims.claim(d.target(table), tableKey(table)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// targetClaims remembers the source table loaded into each Doris table
+type targetClaims struct {
+	mu      sync.Mutex
+	sources map[doris.TableTarget]string
+}
+
+func newTargetClaims() *targetClaims {
+	return &targetClaims{sources: make(map[doris.TableTarget]string)}
+}
+
+// claim fails when another source table is already loaded into the target
+func (c *targetClaims) claim(target doris.TableTarget, source string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if claimed, ok := c.sources[target]; ok && claimed != source {
+		return fmt.Errorf("source tables %s and %s both map to doris table %s, set a table template or target_table to tell them apart", claimed, source, target)
+	}
+	c.sources[target] = source
+	return nil
+}
+
+// FinalizeTable commits the precommitted loads of a table loaded with two phase commit when it succeeded
+// and aborts them otherwise. Loads of other tables are visible as soon as they succeed.
+func (d dorisSyncService) FinalizeTable(ctx context.Context, table dtos.TableInfo, succeeded bool) error {
+	if succeeded {
+		return d.finishTransactions(ctx, tableKey(table), doris.TxnOperationCommit)
+	}
+	return d.finishTransactions(ctx, tableKey(table), doris.TxnOperationAbort)
+}
+
+// Close aborts the precommitted loads of the tables that were never finalized
+func (d dorisSyncService) Close() error {
+	var errs []error
+	for _, key := range d.transactions.keys() {
+		if err := d.finishTransactions(context.Background(), key, doris.TxnOperationAbort); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// doFollowingRedirects sends the request built for the target and follows the redirects of a FE to a BE itself,
+// since net/http drops the credentials when a redirect changes host. It returns the response and the url that answered it.
+// The client must return redirects rather than follow them.
+func doFollowingRedirects(client *http.Client, target string, newRequest func(target string) (*http.Request, error)) (*http.Response, string, error) {
+	for redirects := 0; ; redirects++ {
+		req, err := newRequest(target)
+		if err != nil {
+			return nil, target, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, target, err
+		}
+		if resp.StatusCode != http.StatusTemporaryRedirect {
+			return resp, target, nil
+		}
+
+		location, err := resp.Location()
+		resp.Body.Close()
+		if err != nil {
+			return nil, target, fmt.Errorf("%w: %s answered without a location: %w", errBadRedirect, target, err)
+		}
+		if redirects >= maxRedirects {
+			return nil, target, fmt.Errorf("%w: more than %d redirects from %s", errBadRedirect, maxRedirects, target)
+		}
+		target = location.String()
+	}
+}
+
+// StreamLoadDoris uploads a payload described by the headers to Apache Doris and decodes the result of the load.
+// The payload is opened once per request and sent with chunked transfer encoding as it is read.
+// Aborted loads return ErrStreamLoadFailed and labels of committed loads ErrLabelAlreadyExists along with the result.
+// Requests that may have reached Doris without a conclusive answer return errLoadOutcomeUnknown, and
+// errNodeUnavailable too when the node the request was sent to is to blame.
+func (d dorisSyncService) StreamLoadDoris(ctx context.Context, dorisURL, username, password string, payload func() io.ReadCloser, uniqueLabel string, headers map[string]string) (doris.StreamLoadResult, error) {
+	var result doris.StreamLoadResult
+
+	target := dorisURL
+	// Only failures of the requested node count against it, the BE a FE redirects to is not part of a pool
+	nodeFailure := func(err error) error {
+		if target != dorisURL {
+			return err
+		}
+		return fmt.Errorf("%w: %w", errNodeUnavailable, err)
+	}
+
+	resp, target, err := doFollowingRedirects(d.client, dorisURL, func(target string) (*http.Request, error) {
+		// Create HTTP request
+		body := payload()
+		req, err := http.NewRequestWithContext(ctx, "PUT", target, body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return payload(), nil
+		}
+
+		// Set Stream Load headers
+		req.Header.Set("Expect", "100-continue")
+		req.Header.Set("label", uniqueLabel) // Unique label
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		req.SetBasicAuth(username, password)
+		return req, nil
+	})
+	if err != nil {
+		// A batch that cannot be encoded or a cancelled context leaves the load incomplete, Doris aborts it
+		if ctx.Err() != nil || errors.Is(err, errEncodePayload) || errors.Is(err, errBadRedirect) {
+			return result, fmt.Errorf("stream load for label %s failed: %w", uniqueLabel, err)
+		}
+		return result, fmt.Errorf("%w: failed to send request: %w", errLoadOutcomeUnknown, nodeFailure(err))
 	}
 	defer resp.Body.Close()
 
 	//log the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return result, fmt.Errorf("%w: failed to read response body: %w", errLoadOutcomeUnknown, nodeFailure(err))
 	}
 	//logger.Sugar.Info(string(body))
 
 	// Check response status
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return result, fmt.Errorf("%w: %w", errLoadOutcomeUnknown, nodeFailure(fmt.Errorf("stream load for label %s answered status %s response %s", uniqueLabel, resp.Status, string(body))))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("stream load failed for label %s with status %s response %s", uniqueLabel, resp.Status, string(body))
+		return result, fmt.Errorf("stream load failed for label %s with status %s response %s", uniqueLabel, resp.Status, string(body))
 	}
 
-	logger.Sugar.Infof("✅ Doris Stream Load Successful for label %s with response: %s", uniqueLabel, string(body))
-	return nil
+	return decodeStreamLoadResult(body, uniqueLabel)
+}
+
+// decodeStreamLoadResult decodes the body of a Stream Load answered with 200 into the result of the load and
+// its error. Doris answers 200 for failed loads too, the outcome is in the body.
+func decodeStreamLoadResult(body []byte, uniqueLabel string) (doris.StreamLoadResult, error) {
+	var result doris.StreamLoadResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, fmt.Errorf("%w: failed to decode stream load response for label %s: %w, response %s", errLoadOutcomeUnknown, uniqueLabel, err, string(body))
+	}
+
+	switch {
+	case result.Succeeded():
+		logger.Sugar.Infof("✅ Doris Stream Load Successful for label %s, txn %d: %d/%d rows loaded, %d filtered, %d unselected in %dms",
+			uniqueLabel, result.TxnId, result.NumberLoadedRows, result.NumberTotalRows, result.NumberFilteredRows, result.NumberUnselectedRows, result.LoadTimeMs)
+		return result, nil
+	case result.Status == doris.StreamLoadStatusLabelExists && result.ExistingJobStatus == doris.ExistingJobFinished:
+		return result, fmt.Errorf("%w: label %s was loaded by an earlier job", ErrLabelAlreadyExists, uniqueLabel)
+	case result.Status == doris.StreamLoadStatusLabelExists:
+		// The outcome of the job holding the label is not known yet, it may still fail
+		return result, fmt.Errorf("%w: stream load label %s is held by a %s job", errLoadOutcomeUnknown, uniqueLabel, result.ExistingJobStatus)
+	default:
+		return result, fmt.Errorf("%w: label %s, status %s, message %s, error url %s", ErrStreamLoadFailed, uniqueLabel, result.Status, result.Message, result.ErrorURL)
+	}
 }
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"migration-tool-go/dtos/destinations/doris"
+	"testing"
+)
+
+func TestDecodeStreamLoadResult(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		want    doris.StreamLoadResult
+		wantErr error
+	}{
+		{
+			name: "success",
+			body: `{"TxnId": 7, "Label": "l", "Status": "Success", "NumberTotalRows": 3, "NumberLoadedRows": 3}`,
+			want: doris.StreamLoadResult{TxnId: 7, Label: "l", Status: doris.StreamLoadStatusSuccess, NumberTotalRows: 3, NumberLoadedRows: 3},
+		},
+		{
+			name: "publish timeout is committed",
+			body: `{"TxnId": 8, "Status": "Publish Timeout", "NumberTotalRows": 2, "NumberLoadedRows": 2}`,
+			want: doris.StreamLoadResult{TxnId: 8, Status: doris.StreamLoadStatusPublishTimeout, NumberTotalRows: 2, NumberLoadedRows: 2},
+		},
+		{
+			name:    "fail",
+			body:    `{"Status": "Fail", "Message": "too many filtered rows", "ErrorURL": "http://be/api/_load_error_log?file=x"}`,
+			want:    doris.StreamLoadResult{Status: doris.StreamLoadStatusFail, Message: "too many filtered rows", ErrorURL: "http://be/api/_load_error_log?file=x"},
+			wantErr: ErrStreamLoadFailed,
+		},
+		{
+			name:    "label of a finished job",
+			body:    `{"Status": "Label Already Exists", "ExistingJobStatus": "FINISHED"}`,
+			want:    doris.StreamLoadResult{Status: doris.StreamLoadStatusLabelExists, ExistingJobStatus: doris.ExistingJobFinished},
+			wantErr: ErrLabelAlreadyExists,
+		},
+		{
+			name:    "label of a running job",
+			body:    `{"Status": "Label Already Exists", "ExistingJobStatus": "RUNNING"}`,
+			want:    doris.StreamLoadResult{Status: doris.StreamLoadStatusLabelExists, ExistingJobStatus: "RUNNING"},
+			wantErr: errLoadOutcomeUnknown,
+		},
+		{
+			name:    "unknown status",
+			body:    `{"Status": "Cancelled"}`,
+			want:    doris.StreamLoadResult{Status: "Cancelled"},
+			wantErr: ErrStreamLoadFailed,
+		},
+		{
+			name:    "undecodable body",
+			body:    `<html>bad gateway</html>`,
+			wantErr: errLoadOutcomeUnknown,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := decodeStreamLoadResult([]byte(tc.body), "label")
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+				t.Fatalf("error = %v, want %v", err, tc.wantErr)
+			}
+			if result != tc.want {
+				t.Errorf("result = %+v, want %+v", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadedRows(t *testing.T) {
+	cases := []struct {
+		name       string
+		result     doris.StreamLoadResult
+		rows       int
+		wantLoaded uint64
+		rejected   bool
+	}{
+		{"every row loaded", doris.StreamLoadResult{NumberTotalRows: 5, NumberLoadedRows: 5}, 5, 5, false},
+		{"unselected rows count as read", doris.StreamLoadResult{NumberTotalRows: 5, NumberLoadedRows: 4, NumberUnselectedRows: 1}, 5, 4, false},
+		{"filtered rows", doris.StreamLoadResult{NumberTotalRows: 5, NumberLoadedRows: 3, NumberFilteredRows: 2}, 5, 3, true},
+		{"rows not read", doris.StreamLoadResult{NumberTotalRows: 4, NumberLoadedRows: 4}, 5, 4, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			loaded, err := loadedRows(tc.result, tc.rows, "label")
+			if loaded != tc.wantLoaded {
+				t.Errorf("loaded = %d, want %d", loaded, tc.wantLoaded)
+			}
+			if rejected := errors.Is(err, ErrRowsRejected); rejected != tc.rejected || (!tc.rejected && err != nil) {
+				t.Errorf("error = %v, want rejected %v", err, tc.rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/destinations/doris"
+	"strings"
+
+	"github.com/pierrec/lz4/v4"
+	"github.com/samber/lo"
+)
+
+// errEncodePayload marks a batch that could not be serialized, sending it again fails the same way
+var errEncodePayload = errors.New("failed to encode payload")
+
+const (
+	// csvNull is how Doris reads NULL in csv payloads
+	csvNull = `\N`
+	// csvEnclose and csvEscape quote the csv fields that contain a separator
+	csvEnclose = `"`
+	csvEscape  = `\`
+)
+
+// loadBatch is a batch of records with the columns it loads, the delete sign of change data capture included
+type loadBatch struct {
+	records []map[string]any
+	columns []dtos.ColumnInfo
+	// explicitColumns sends the columns header with JSON payloads too, they otherwise load every key
+	explicitColumns bool
+	// rename maps source columns to the Doris columns they are loaded into
+	rename map[string]string
+}
+
+// loadEncoder serializes batches into the configured Stream Load format and compression
+type loadEncoder struct {
+	config          doris.LoadConfiguration
+	columnSeparator string
+	lineDelimiter   string
+}
+
+func newLoadEncoder(config doris.LoadConfiguration) (*loadEncoder, error) {
+	config.SetDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	encoder := &loadEncoder{config: config}
+	if config.Format == doris.LoadFormatCsv {
+		// Validate already checked that both decode
+		encoder.columnSeparator, _ = doris.DecodeSeparator(config.ColumnSeparator)
+		encoder.lineDelimiter, _ = doris.DecodeSeparator(config.LineDelimiter)
+	}
+	return encoder, nil
+}
+
+// Headers returns the Stream Load headers describing the payload of a batch
+func (e *loadEncoder) Headers(batch loadBatch) map[string]string {
+	headers := make(map[string]string)
+	columns := strings.Join(lo.Map(batch.columns, func(column dtos.ColumnInfo, _ int) string {
+		if name, ok := batch.rename[column.Name]; ok {
+			return quoteDorisIdentifier(name)
+		}
+		return quoteDorisIdentifier(column.Name)
+	}), ",")
+	switch e.config.Format {
+	case doris.LoadFormatJson:
+		headers["Content-Type"] = "application/json"
+		headers["format"] = "json"
+		headers["strip_outer_array"] = "true"
+	case doris.LoadFormatNdjson:
+		headers["Content-Type"] = "application/json"
+		headers["format"] = "json"
+		headers["read_json_by_line"] = "true"
+	case doris.LoadFormatCsv:
+		headers["format"] = "csv"
+		headers["column_separator"] = doris.EncodeSeparator(e.columnSeparator)
+		headers["line_delimiter"] = doris.EncodeSeparator(e.lineDelimiter)
+		headers["enclose"] = csvEnclose
+		headers["escape"] = csvEscape
+		headers["columns"] = columns
+	}
+
+	// Change data capture marks deleted rows with the hidden delete sign column, the columns header lists it
+	if e.config.Format != doris.LoadFormatCsv {
+		_, deletes := batch.records[0][doris.DeleteSignColumn]
+		if len(batch.rename) > 0 {
+			// JSON keys are matched to Doris columns by name, renamed columns are read by path and loaded in order
+			headers["columns"] = columns
+			headers["jsonpaths"] = jsonPaths(batch.columns)
+		} else if batch.explicitColumns {
+			headers["columns"] = columns
+		} else if deletes {
+			headers["hidden_columns"] = doris.DeleteSignColumn
+		}
+	}
+	if e.config.Compression != doris.LoadCompressionNone {
+		headers["compress_type"] = e.config.Compression
+	}
+	return headers
+}
+
+// jsonPaths returns the jsonpaths header reading the keys of the columns in order
+func jsonPaths(columns []dtos.ColumnInfo) string {
+	paths, _ := json.Marshal(lo.Map(columns, func(column dtos.ColumnInfo, _ int) string {
+		return "$." + column.Name
+	}))
+	return string(paths)
+}
+
+// Payload returns a function opening the payload of a batch, the rows are serialized while the request body is read.
+// Every call encodes the batch again, so each attempt and redirect gets its own body.
+func (e *loadEncoder) Payload(batch loadBatch) func() io.ReadCloser {
+	return func() io.ReadCloser {
+		reader, writer := io.Pipe()
+		go func() {
+			// Closing the reader, as net/http does once the request ends, unblocks and stops the encoding
+			writer.CloseWithError(e.write(writer, batch))
+		}()
+		return reader
+	}
+}
+
+// write encodes and compresses the batch through a buffer of the configured size
+func (e *loadEncoder) write(w io.Writer, batch loadBatch) error {
+	records := batch.records
+	buffered := bufio.NewWriterSize(w, e.config.BufferSizeBytes)
+	compressed, err := e.compress(buffered)
+	if err != nil {
+		return err
+	}
+
+	switch e.config.Format {
+	case doris.LoadFormatJson:
+		err = e.writeJson(compressed, records)
+	case doris.LoadFormatNdjson:
+		err = e.writeNdjson(compressed, records)
+	case doris.LoadFormatCsv:
+		err = e.writeCsv(compressed, batch.columns, records)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %d records as %s: %w", errEncodePayload, len(records), e.config.Format, err)
+	}
+
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("failed to compress %d records: %w", len(records), err)
+	}
+	return buffered.Flush()
+}
+
+// compress wraps the payload in the configured compression, the returned writer must be closed to flush it
+func (e *loadEncoder) compress(w io.Writer) (io.WriteCloser, error) {
+	switch e.config.Compression {
+	case doris.LoadCompressionGzip:
+		return gzip.NewWriter(w), nil
+	case doris.LoadCompressionLz4:
+		// Doris reads the lz4 frame format, small blocks keep the buffered data bounded
+		writer := lz4.NewWriter(w)
+		if err := writer.Apply(lz4.BlockSizeOption(lz4.Block256Kb)); err != nil {
+			return nil, fmt.Errorf("failed to configure lz4: %w", err)
+		}
+		return writer, nil
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// writeJson streams the records as one JSON array, an element at a time
+func (e *loadEncoder) writeJson(w io.Writer, records []map[string]any) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i, record := range records {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+func (e *loadEncoder) writeNdjson(w io.Writer, records []map[string]any) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		// Encode terminates every object with a newline
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *loadEncoder) writeCsv(w io.Writer, columns []dtos.ColumnInfo, records []map[string]any) error {
+	var line strings.Builder
+	for _, record := range records {
+		line.Reset()
+		for i, column := range columns {
+			if i > 0 {
+				line.WriteString(e.columnSeparator)
+			}
+			// Values are rendered the way Doris reads them back, the form validation compares
+			value, ok := normalizeSourceValue(column.DataType, record[column.Name])
+			if !ok {
+				line.WriteString(csvNull)
+				continue
+			}
+			line.WriteString(e.csvField(value))
+		}
+		line.WriteString(e.lineDelimiter)
+
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvField encloses the values that contain a separator, the enclose or escape character, or read as NULL.
+// A value ending with the start of a multi byte separator is enclosed too, with the separator that follows it
+// the field would otherwise read as ending earlier.
+func (e *loadEncoder) csvField(value string) string {
+	if value != csvNull &&
+		!strings.Contains(value, e.columnSeparator) &&
+		!strings.Contains(value, e.lineDelimiter) &&
+		!strings.ContainsAny(value, csvEnclose+csvEscape) &&
+		!endsWithPrefix(value, e.columnSeparator) &&
+		!endsWithPrefix(value, e.lineDelimiter) {
+		return value
+	}
+	escaped := strings.NewReplacer(csvEscape, csvEscape+csvEscape, csvEnclose, csvEscape+csvEnclose).Replace(value)
+	return csvEnclose + escaped + csvEnclose
+}
+
+// endsWithPrefix reports whether value ends with a proper prefix of separator
+func endsWithPrefix(value string, separator string) bool {
+	for i := 1; i < len(separator); i++ {
+		if strings.HasSuffix(value, separator[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/destinations/doris"
+	"testing"
+)
+
+func newTestCsvEncoder(t *testing.T, columnSeparator string, lineDelimiter string) *loadEncoder {
+	t.Helper()
+	encoder, err := newLoadEncoder(doris.LoadConfiguration{Format: doris.LoadFormatCsv, ColumnSeparator: columnSeparator, LineDelimiter: lineDelimiter})
+	if err != nil {
+		t.Fatalf("newLoadEncoder: %v", err)
+	}
+	return encoder
+}
+
+func TestCsvField(t *testing.T) {
+	cases := []struct {
+		name            string
+		columnSeparator string
+		value           string
+		want            string
+	}{
+		{"plain", "\t", "plain", "plain"},
+		{"empty", "\t", "", ""},
+		{"column separator", "\t", "a\tb", "\"a\tb\""},
+		{"line delimiter", "\t", "a\nb", "\"a\nb\""},
+		{"enclose character", "\t", `say "hi"`, `"say \"hi\""`},
+		{"escape character", "\t", `C:\dir`, `"C:\\dir"`},
+		{"NULL marker", "\t", `\N`, `"\\N"`},
+		{"NULL text", "\t", "NULL", "NULL"},
+		{"multi byte separator", "||", "a|b", "a|b"},
+		{"multi byte separator inside", "||", "a||b", `"a||b"`},
+		{"ends with the start of a multi byte separator", "||", "a|", `"a|"`},
+		{"ends with the start of a longer separator", "|#|", "a|#", `"a|#"`},
+		{"control character separator", `\x01`, "a\x01b", "\"a\x01b\""},
+		{"tab with control character separator", `\x01`, "a\tb", "a\tb"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoder := newTestCsvEncoder(t, tc.columnSeparator, "\n")
+			if got := encoder.csvField(tc.value); got != tc.want {
+				t.Errorf("csvField(%q) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteCsv(t *testing.T) {
+	encoder := newTestCsvEncoder(t, `\x01`, `\x02`)
+	columns := []dtos.ColumnInfo{{Name: "id", DataType: "int4"}, {Name: "note", DataType: "text"}, {Name: "missing", DataType: "text"}}
+	records := []map[string]any{
+		{"id": int32(1), "note": "a\x01b", "missing": nil},
+		{"id": int32(2), "note": `\N`},
+	}
+
+	var payload bytes.Buffer
+	if err := encoder.writeCsv(&payload, columns, records); err != nil {
+		t.Fatalf("writeCsv: %v", err)
+	}
+	want := "1\x01\"a\x01b\"\x01\\N\x02" + "2\x01\"\\\\N\"\x01\\N\x02"
+	if got := payload.String(); got != want {
+		t.Errorf("payload = %q, want %q", got, want)
+	}
+
+	headers := encoder.Headers(loadBatch{records: records, columns: columns})
+	if headers["column_separator"] != `\x01` || headers["line_delimiter"] != `\x02` {
+		t.Errorf("separator headers = %q and %q, want \\x01 and \\x02", headers["column_separator"], headers["line_delimiter"])
+	}
+	if headers["enclose"] != csvEnclose || headers["escape"] != csvEscape {
+		t.Errorf("enclose and escape headers = %q and %q", headers["enclose"], headers["escape"])
+	}
+}
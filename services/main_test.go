@@ -0,0 +1,14 @@
+package services
+
+import (
+	"migration-tool-go/logger"
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Sugar = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
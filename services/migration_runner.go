@@ -2,11 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"migration-tool-go/dtos"
 	"migration-tool-go/dtos/common"
 	"migration-tool-go/logger"
 	"migration-tool-go/utils"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,41 +19,43 @@ import (
 var MigrationRunner = &migrationRunner{}
 
 type migrationRunner struct {
-	startTime     time.Time
+	startTime time.Time
+	// runId prefixes the Stream Load labels of the run, a resumed run keeps the id of the interrupted one
+	runId string
+	// failedRecords, rejectedRecords and deferredCommit are keyed by schema.table
 	failedRecords map[string][]map[string]any
-	workerConfig  *common.WorkerConfiguration
+	// rejectedRecords counts per table the rows the destination dropped from committed batches
+	rejectedRecords map[string]uint64
+	// failedRanges counts per table the key ranges whose records could not be read
+	failedRanges map[string]uint64
+	// unresumedTables are the tables left out of a resumed run because they cannot resume
+	unresumedMu     sync.Mutex
+	unresumedTables []string
+	// deferredCommit marks the tables whose batches only become visible once FinalizeTable commits them
+	deferredCommit map[string]bool
+	workerConfig   *common.WorkerConfiguration
+	source         Source
+	sink           Sink
+	// tableCompleted is called once a table has been fully loaded and finalized
+	tableCompleted func(ctx context.Context, table dtos.TableInfo)
 }
 
 // Initialize sets up the migration runner
-func NewMigrationRunner(workerConfig common.WorkerConfiguration) {
+func NewMigrationRunner(source Source, sink Sink, workerConfig common.WorkerConfiguration) {
 	// Set default values for worker configuration if not provided
-	// WorkerBatchSize: Number of workers to use for processing
-	if workerConfig.WorkerBatchSize <= 0 {
-		workerConfig.WorkerBatchSize = 10000 // Default worker pool size
-	}
-	// IdBatchSize: Batch size for fetching primary key IDs from the source
-	if workerConfig.IdBatchSize <= 0 {
-		workerConfig.IdBatchSize = 10000 // Default ID batch size
-	}
-	// ConcurrentTables: Number of tables to process concurrently
-	if workerConfig.ConcurrentTables <= 0 {
-		workerConfig.ConcurrentTables = 10 // Default concurrent tables
-	}
-	// BatchProcessingTimeoutMs: Timeout in milliseconds for batch processing
-	if workerConfig.BatchProcessingTimeoutMs <= 0 {
-		workerConfig.BatchProcessingTimeoutMs = 500 // Default 500ms timeout
-	}
-	// RecordBatchSize: Number of records to process in a single batch
-	if workerConfig.RecordBatchSize <= 0 {
-		workerConfig.RecordBatchSize = 5000 // Default record batch size
-	}
+	workerConfig.SetDefaults()
 
+	startTime := time.Now()
 	MigrationRunner = &migrationRunner{
-		startTime:    time.Now(),
+		startTime:    startTime,
+		runId:        CheckpointService.RunId(),
 		workerConfig: &workerConfig,
+		source:       source,
+		sink:         sink,
 	}
 
-	logger.Sugar.Infof("Migration runner initialized with workers: %d, worker batch size: %d, id batch size: %d, record batch size: %d, concurrent tables: %d, batch processing timeout: %dms",
+	logger.Sugar.Infof("Migration runner initialized with run id %s, workers: %d, worker batch size: %d, id batch size: %d, record batch size: %d, concurrent tables: %d, batch processing timeout: %dms",
+		MigrationRunner.runId,
 		workerConfig.NoOfWorkers,
 		workerConfig.WorkerBatchSize,
 		workerConfig.IdBatchSize,
@@ -63,17 +68,22 @@ func NewMigrationRunner(workerConfig common.WorkerConfiguration) {
 func (m *migrationRunner) Run(ctx context.Context) error {
 	// Use concurrent tables from config to determine buffer size
 	tableInfoChan := make(chan *dtos.TableInfoChan, m.workerConfig.ConcurrentTables)
-	processedAllTables := false
 	// Map to track failed records by table name
 	m.failedRecords = make(map[string][]map[string]any)
+	m.rejectedRecords = make(map[string]uint64)
+	m.failedRanges = make(map[string]uint64)
+	m.deferredCommit = make(map[string]bool)
 
 	// Start the source data extraction in a goroutine
 	go func() {
-		if err := PostgresMigration.GetRecordsFromSource(ctx, tableInfoChan, &processedAllTables); err != nil {
+		if err := m.extractTables(ctx, tableInfoChan); err != nil {
 			logger.Sugar.Errorf("Error getting records from source: %v", err)
 		}
 	}()
 
+	// Key strategy of every processed table, reported once the run ends
+	var keyStrategies []string
+
 	// Process the data
 	exitTableProcessing := false
 	for !exitTableProcessing {
@@ -84,13 +94,10 @@ func (m *migrationRunner) Run(ctx context.Context) error {
 				break
 			}
 
-			// Process the received table information
-			records := m.processTableInfo(infoChan)
+			keyStrategies = append(keyStrategies, fmt.Sprintf("%s.%s: %s", infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, describeKeyStrategy(infoChan.TableInfo)))
 
-			// Check if we should exit the loop
-			if len(records) == 0 && processedAllTables {
-				exitTableProcessing = true
-			}
+			// Process the received table information
+			m.processTableInfo(ctx, infoChan)
 		case <-ctx.Done():
 			// Context cancelled or timed out
 			logger.Sugar.Info("Migration stopped due to context cancellation")
@@ -99,10 +106,13 @@ func (m *migrationRunner) Run(ctx context.Context) error {
 	}
 
 	logger.Sugar.Infof("Migration completed. Total time taken: %s", time.Since(m.startTime))
+	for _, keyStrategy := range keyStrategies {
+		logger.Sugar.Infof("Key strategy of %s", keyStrategy)
+	}
 
-	// Handle any failed records if needed
-	if len(m.failedRecords) > 0 {
-		logger.Sugar.Infof("There were failed records: %d tables had failures", len(m.failedRecords))
+	// Count the tables like the handoff to change data capture does
+	if failedTables := m.FailedTables(); failedTables > 0 {
+		logger.Sugar.Infof("There were failures: %d tables had failed, rejected or unread records", failedTables)
 
 		// Save failed records to files for later analysis or retry
 		for tableName, records := range m.failedRecords {
@@ -119,20 +129,152 @@ func (m *migrationRunner) Run(ctx context.Context) error {
 		}
 	}
 
+	for tableName, count := range m.rejectedRecords {
+		if count > 0 {
+			logger.Sugar.Warnf("The destination rejected %d records of table %s, see the error urls logged with the batches", count, tableName)
+		}
+	}
+	for tableName, count := range m.failedRanges {
+		logger.Sugar.Warnf("Failed to read %d key ranges of table %s, it is not marked completed", count, tableName)
+	}
+
 	return nil
 }
 
+// OnTableCompleted registers a hook called after every table that was loaded without failures
+func (m *migrationRunner) OnTableCompleted(hook func(ctx context.Context, table dtos.TableInfo)) {
+	m.tableCompleted = hook
+}
+
+// FailedTables returns the number of tables that had records which could not be loaded or were rejected
+func (m *migrationRunner) FailedTables() int {
+	failedTables := make(map[string]bool)
+	for tableName, records := range m.failedRecords {
+		if len(records) > 0 {
+			failedTables[tableName] = true
+		}
+	}
+	for tableName, count := range m.rejectedRecords {
+		if count > 0 {
+			failedTables[tableName] = true
+		}
+	}
+	for tableName := range m.failedRanges {
+		failedTables[tableName] = true
+	}
+	m.unresumedMu.Lock()
+	for _, tableName := range m.unresumedTables {
+		failedTables[tableName] = true
+	}
+	m.unresumedMu.Unlock()
+	return len(failedTables)
+}
+
+// extractTables discovers the source tables and extracts up to ConcurrentTables of them in parallel.
+// tableInfoChan is closed once every table has been read.
+func (m *migrationRunner) extractTables(ctx context.Context, tableInfoChan chan *dtos.TableInfoChan) error {
+	defer close(tableInfoChan)
+
+	tableInfoList, err := m.source.DiscoverTables(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+
+	concurrentTables := make(chan bool, m.workerConfig.ConcurrentTables)
+	defer close(concurrentTables)
+
+	for _, tableInfo := range tableInfoList {
+		if CheckpointService.IsCompleted(tableInfo.TableSchema, tableInfo.TableName) {
+			logger.Sugar.Infof("Skipping table %s.%s, it was completed by a previous run", tableInfo.TableSchema, tableInfo.TableName)
+			continue
+		}
+
+		checkpoint, hasCheckpoint := CheckpointService.Get(tableInfo.TableSchema, tableInfo.TableName)
+		// The blocks loaded after the last committed range of a ctid table are unknown and its Duplicate Key
+		// table would keep their rows twice. Batches held until FinalizeTable were aborted, such tables restart.
+		if hasCheckpoint && checkpoint.KeyStrategy == dtos.KeyStrategyCtid && tableInfo.KeyStrategy == dtos.KeyStrategyCtid && !commitsOnFinalize(m.sink, tableInfo) {
+			logger.Sugar.Errorf("Skipping table %s.%s, a ctid table cannot be resumed: truncate its destination table and copy it again without -resume", tableInfo.TableSchema, tableInfo.TableName)
+			m.unresumedMu.Lock()
+			m.unresumedTables = append(m.unresumedTables, tableKey(tableInfo))
+			m.unresumedMu.Unlock()
+			continue
+		}
+
+		infoChan := dtos.NewTableInfoChan(tableInfo, m.workerConfig.WorkerBatchSize, m.workerConfig.IdBatchSize)
+		if hasCheckpoint {
+			infoChan.Checkpoint = &checkpoint
+		}
+
+		logger.Sugar.Infof("Extracting table %s.%s with key strategy %s", tableInfo.TableSchema, tableInfo.TableName, describeKeyStrategy(tableInfo))
+		if err := CheckpointService.SetKeyStrategy(tableInfo.TableSchema, tableInfo.TableName, tableInfo.KeyStrategy); err != nil {
+			logger.Sugar.Errorf("Failed to record key strategy of %s.%s: %v", tableInfo.TableSchema, tableInfo.TableName, err)
+		}
+		tableInfoChan <- infoChan
+		concurrentTables <- true
+
+		wg.Add(1)
+		go m.extractTable(ctx, infoChan, concurrentTables, &wg)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// extractTable plans the key ranges of a single table and streams its records
+func (m *migrationRunner) extractTable(ctx context.Context, infoChan *dtos.TableInfoChan, concurrentTables chan bool, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer func() { <-concurrentTables }()
+
+	go func() {
+		if err := m.source.PlanKeyRanges(ctx, infoChan); err != nil {
+			// The keys after the failure are never planned, the table fails instead of completing without them
+			logger.Sugar.Errorf("Failed to plan key ranges for table %s.%s: %v", infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, err)
+			infoChan.IncrementFailedRanges(0)
+		}
+		infoChan.ReadingIdsDone.Store(true)
+	}()
+
+	m.source.StreamRecords(ctx, infoChan)
+}
+
 // processTableInfo handles the processing of a single table's data
-func (m *migrationRunner) processTableInfo(infoChan *dtos.TableInfoChan) []map[string]any {
-	var records []map[string]any
+func (m *migrationRunner) processTableInfo(ctx context.Context, infoChan *dtos.TableInfoChan) []dtos.Record {
+	var records []dtos.Record
 	checkAllRecordsProcessed := make(map[string]uint64)
 	processedRecordsChan := false
+	completed := false
 
 	// Initialize failed records tracking for this table if needed
-	if _, exists := m.failedRecords[infoChan.TableInfo.TableName]; !exists {
-		m.failedRecords[infoChan.TableInfo.TableName] = []map[string]any{}
+	if _, exists := m.failedRecords[tableKey(infoChan.TableInfo)]; !exists {
+		m.failedRecords[tableKey(infoChan.TableInfo)] = []map[string]any{}
 	}
 
+	if err := m.sink.PrepareTable(ctx, infoChan.TableInfo); err != nil {
+		logger.Sugar.Errorf("Failed to prepare destination table %s: %v", infoChan.TableInfo.TableName, err)
+	}
+	// Checkpoints of such tables wait for the commit, a resumed run reloads them from the start
+	m.deferredCommit[tableKey(infoChan.TableInfo)] = commitsOnFinalize(m.sink, infoChan.TableInfo)
+
+	defer func() {
+		succeeded := completed && ctx.Err() == nil && len(m.failedRecords[tableKey(infoChan.TableInfo)]) == 0 && m.rejectedRecords[tableKey(infoChan.TableInfo)] == 0 && infoChan.GetFailedRanges() == 0
+		if err := m.sink.FinalizeTable(ctx, infoChan.TableInfo, succeeded); err != nil {
+			logger.Sugar.Errorf("Failed to finalize destination table %s: %v", infoChan.TableInfo.TableName, err)
+			return
+		}
+		if succeeded && m.deferredCommit[tableKey(infoChan.TableInfo)] {
+			if err := CheckpointService.MarkCompleted(infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName); err != nil {
+				logger.Sugar.Errorf("Failed to mark table %s.%s as completed: %v", infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, err)
+			}
+		}
+		if succeeded && m.tableCompleted != nil {
+			m.tableCompleted(ctx, infoChan.TableInfo)
+		}
+	}()
+
 	// Process records from the channel
 	for !processedRecordsChan {
 		select {
@@ -153,7 +295,7 @@ func (m *migrationRunner) processTableInfo(infoChan *dtos.TableInfoChan) []map[s
 			if len(records) >= m.workerConfig.RecordBatchSize {
 				// Process exactly the record batch size number of records
 				batchRecords := records[:m.workerConfig.RecordBatchSize]
-				m.processBatch(infoChan, batchRecords, checkAllRecordsProcessed)
+				m.processBatch(ctx, infoChan, batchRecords, checkAllRecordsProcessed)
 
 				// Keep any remaining records for the next batch
 				if len(records) > m.workerConfig.RecordBatchSize {
@@ -170,13 +312,13 @@ func (m *migrationRunner) processTableInfo(infoChan *dtos.TableInfoChan) []map[s
 				if len(records) >= m.workerConfig.RecordBatchSize {
 					// Process exactly the record batch size number of records
 					batchRecords := records[:m.workerConfig.RecordBatchSize]
-					m.processBatch(infoChan, batchRecords, checkAllRecordsProcessed)
+					m.processBatch(ctx, infoChan, batchRecords, checkAllRecordsProcessed)
 
 					// Keep any remaining records for the next batch
 					records = records[m.workerConfig.RecordBatchSize:]
 				} else {
 					// Process all remaining records if less than batch size
-					m.processBatch(infoChan, records, checkAllRecordsProcessed)
+					m.processBatch(ctx, infoChan, records, checkAllRecordsProcessed)
 					records = nil
 				}
 			}
@@ -184,7 +326,13 @@ func (m *migrationRunner) processTableInfo(infoChan *dtos.TableInfoChan) []map[s
 			// Check if we're done processing all records for this table
 			if m.checkTableProcessed(infoChan, checkAllRecordsProcessed) {
 				processedRecordsChan = true
+				completed = true
 			}
+
+		case <-ctx.Done():
+			// The table is finalized as failed, which aborts its deferred batches
+			logger.Sugar.Warnf("Migration for table %s cancelled", infoChan.TableInfo.TableName)
+			processedRecordsChan = true
 		}
 	}
 
@@ -192,7 +340,7 @@ func (m *migrationRunner) processTableInfo(infoChan *dtos.TableInfoChan) []map[s
 }
 
 // processBatch handles processing a batch of records
-func (m *migrationRunner) processBatch(infoChan *dtos.TableInfoChan, records []map[string]any, checkAllRecordsProcessed map[string]uint64) {
+func (m *migrationRunner) processBatch(ctx context.Context, infoChan *dtos.TableInfoChan, records []dtos.Record, checkAllRecordsProcessed map[string]uint64) {
 	logger.Sugar.Infof("Migration for table %s in progress, batch size: %d/%d records, batch timeout: %dms, total uuids read: %d, total records read: %d, total records processed: %d, time taken: %s",
 		infoChan.TableInfo.TableName,
 		len(records),
@@ -204,26 +352,98 @@ func (m *migrationRunner) processBatch(infoChan *dtos.TableInfoChan, records []m
 		time.Since(m.startTime).String(),
 	)
 
-	// Generate a unique tracking ID for this batch
-	uuidStr := uuid.New().String()
+	// The label identifies the batch, so a retried batch cannot be loaded twice
+	uuidStr := batchLabel(m.runId, infoChan.TableInfo, records, 1)
+	for occurrence := 2; lo.HasKey(checkAllRecordsProcessed, uuidStr); occurrence++ {
+		// Only tables without a key can produce two batches of identical rows
+		uuidStr = batchLabel(m.runId, infoChan.TableInfo, records, occurrence)
+	}
 
-	// Convert records to JSON for Doris
-	bytesData, err := utils.ConvertRecordsToJSON(records, fmt.Sprintf("final/%s_debug.json", uuidStr), false)
+	values := lo.Map(records, func(record dtos.Record, _ int) map[string]any { return record.Values })
+	// Failed records are kept transformed, raw values never leave the source
+	kept, err := TransformService.Apply(infoChan.TableInfo, values)
 	if err != nil {
-		logger.Sugar.Errorf("Failed to marshal records for table %s: %v", infoChan.TableInfo.TableName, err)
-		// Add the records to the failed records collection
-		m.failedRecords[infoChan.TableInfo.TableName] = append(m.failedRecords[infoChan.TableInfo.TableName], records...)
+		logger.Sugar.Errorf("Failed to transform batch for table %s: %v", infoChan.TableInfo.TableName, err)
+		m.failedRecords[tableKey(infoChan.TableInfo)] = append(m.failedRecords[tableKey(infoChan.TableInfo)], values...)
 		return
 	}
+	// Records dropped by drop_when are done with, like loaded ones
+	dropped := uint64(len(values) - len(kept))
 
-	// Send the data to Doris
-	err = DorisSyncService.SyncDoris(bytesData, uint64(len(records)), infoChan.TableInfo.TableName, uuidStr, checkAllRecordsProcessed)
+	// Send the data to the destination
+	var loaded uint64
+	if len(kept) > 0 {
+		loaded, err = m.sink.WriteBatch(ctx, infoChan.TableInfo, kept, uuidStr)
+	}
+	if errors.Is(err, ErrRowsRejected) {
+		// The batch is committed without the rejected rows, its key ranges stay incomplete
+		logger.Sugar.Errorf("Destination rejected %d of %d rows of a batch for table %s: %v", uint64(len(kept))-loaded, len(kept), infoChan.TableInfo.TableName, err)
+		checkAllRecordsProcessed[uuidStr] = loaded + dropped
+		m.rejectedRecords[tableKey(infoChan.TableInfo)] += uint64(len(kept)) - loaded
+		return
+	}
 	if err != nil {
-		logger.Sugar.Errorf("Failed to sync data to Doris for table %s: %v", infoChan.TableInfo.TableName, err)
+		logger.Sugar.Errorf("Failed to write batch to destination for table %s: %v", infoChan.TableInfo.TableName, err)
 		// Add the records to the failed records collection
-		m.failedRecords[infoChan.TableInfo.TableName] = append(m.failedRecords[infoChan.TableInfo.TableName], records...)
+		m.failedRecords[tableKey(infoChan.TableInfo)] = append(m.failedRecords[tableKey(infoChan.TableInfo)], kept...)
+		checkAllRecordsProcessed[uuidStr] = dropped
 		return
 	}
+
+	checkAllRecordsProcessed[uuidStr] = loaded + dropped
+
+	// Only a batch loaded in full and visible can complete its key ranges
+	if loaded == uint64(len(kept)) && !m.deferredCommit[tableKey(infoChan.TableInfo)] {
+		m.commitCheckpoint(infoChan, records)
+	}
+}
+
+// batchLabel derives the Stream Load label of a batch from the run id, the table, its predicates and the keys of
+// its records. Range sequence numbers restart in a resumed run, so they are left out and the same rows get the same label.
+func batchLabel(runId string, table dtos.TableInfo, records []dtos.Record, occurrence int) string {
+	hash := fnv.New64a()
+	// Incremental windows of a table load the same keys again under other predicates
+	for _, predicate := range table.Predicates {
+		fmt.Fprintf(hash, "%s|%v\n", predicate.Clause, predicate.Args)
+	}
+	for _, record := range records {
+		// Tables read by ctid have no key, their rows are told apart by all their values
+		columns := lo.Map(table.PrimaryKeys, func(key dtos.PrimaryKey, _ int) string { return key.ColumnName })
+		if len(columns) == 0 {
+			columns = lo.Map(table.Columns, func(column dtos.ColumnInfo, _ int) string { return column.Name })
+		}
+		for _, column := range columns {
+			value, ok := normalizeSourceValue("", record.Values[column])
+			fmt.Fprintf(hash, "|%t:%s", ok, value)
+		}
+		hash.Write([]byte{'\n'})
+	}
+
+	suffix := fmt.Sprintf("_%016x", hash.Sum64())
+	if occurrence > 1 {
+		suffix += fmt.Sprintf("_%d", occurrence)
+	}
+
+	// Doris labels are at most 128 characters, the table name is shortened to keep the run id and the hash
+	name := labelUnsafeCharacters.ReplaceAllString(table.TableSchema+"_"+table.TableName, "_")
+	prefix := labelUnsafeCharacters.ReplaceAllString(runId, "_") + "_"
+	if room := 128 - len(prefix) - len(suffix); len(name) > room {
+		name = name[:max(room, 0)]
+	}
+	return prefix + name + suffix
+}
+
+// commitCheckpoint acknowledges the loaded records and persists the highest fully loaded key range
+func (m *migrationRunner) commitCheckpoint(infoChan *dtos.TableInfoChan, records []dtos.Record) {
+	loadedBySeq := lo.CountValuesBy(records, func(record dtos.Record) uint64 { return record.RangeSeq })
+
+	infoChan.Ranges.Acknowledge(lo.MapValues(loadedBySeq, func(count int, _ uint64) uint64 { return uint64(count) }))
+
+	if keyRange, ok := infoChan.Ranges.Advance(); ok {
+		if err := CheckpointService.CommitRange(infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, keyRange); err != nil {
+			logger.Sugar.Errorf("Failed to commit checkpoint for table %s.%s: %v", infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, err)
+		}
+	}
 }
 
 // checkTableProcessed determines if processing for a table is complete
@@ -234,12 +454,15 @@ func (m *migrationRunner) checkTableProcessed(
 	// Check if we've read all records and processed all records
 	totalProcessed := lo.Sum(lo.Values(checkAllRecordsProcessed))
 
-	// Consider failed records when determining if we're done
-	totalFailedRecords := len(m.failedRecords[infoChan.TableInfo.TableName])
+	// Consider failed and rejected records when determining if we're done
+	totalFailedRecords := len(m.failedRecords[tableKey(infoChan.TableInfo)])
+	totalRejectedRecords := m.rejectedRecords[tableKey(infoChan.TableInfo)]
+	// Rows of ranges that failed to read are neither read nor processed, the table is incomplete without them
+	failedRanges := infoChan.GetFailedRanges()
 
 	// If we've read all records and processed all of them (including failures), we're done with this table
-	if infoChan.ReadingRecordsDone.Load().(bool) && (totalProcessed+uint64(totalFailedRecords)) == infoChan.GetTotalRecordsRead() {
-		logger.Sugar.Infof("Migration for table %s completed, workers: %d, batch size: %d, batch timeout: %dms, total uuids read: %d, total records read: %d, total records processed: %d, failed records: %d, time taken: %s",
+	if infoChan.ReadingRecordsDone.Load().(bool) && (totalProcessed+uint64(totalFailedRecords)+totalRejectedRecords) == infoChan.GetTotalRecordsRead() {
+		logger.Sugar.Infof("Migration for table %s completed, workers: %d, batch size: %d, batch timeout: %dms, total uuids read: %d, total records read: %d, total records processed: %d, failed records: %d, rejected records: %d, failed ranges: %d, time taken: %s",
 			infoChan.TableInfo.TableName,
 			m.workerConfig.NoOfWorkers,
 			m.workerConfig.RecordBatchSize,
@@ -248,10 +471,30 @@ func (m *migrationRunner) checkTableProcessed(
 			infoChan.GetTotalRecordsRead(),
 			totalProcessed,
 			totalFailedRecords,
+			totalRejectedRecords,
+			failedRanges,
 			time.Since(m.startTime).String(),
 		)
+		if failedRanges > 0 {
+			m.failedRanges[tableKey(infoChan.TableInfo)] = failedRanges
+		}
+
+		// Tables with deferred batches are marked completed once FinalizeTable commits them
+		if totalFailedRecords == 0 && totalRejectedRecords == 0 && failedRanges == 0 && !m.deferredCommit[tableKey(infoChan.TableInfo)] {
+			if err := CheckpointService.MarkCompleted(infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName); err != nil {
+				logger.Sugar.Errorf("Failed to mark table %s.%s as completed: %v", infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, err)
+			}
+		}
 		return true
 	}
 
 	return false
 }
+
+// describeKeyStrategy names the key strategy of a table along with the index it uses
+func describeKeyStrategy(tableInfo dtos.TableInfo) string {
+	if tableInfo.KeyIndex != "" {
+		return fmt.Sprintf("%s (%s)", tableInfo.KeyStrategy, tableInfo.KeyIndex)
+	}
+	return tableInfo.KeyStrategy
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/common"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+)
+
+// planService estimates the migration of the selected tables without reading or loading any row
+type planService struct {
+	source       *postgresMigration
+	sink         *dorisSyncService
+	workerConfig common.WorkerConfiguration
+}
+
+// NewPlanService creates the plan service for a postgres source and a doris sink
+func NewPlanService(source Source, sink Sink, workerConfig common.WorkerConfiguration) (*planService, error) {
+	postgresSource, ok := source.(*postgresMigration)
+	if !ok {
+		return nil, fmt.Errorf("planning requires a postgres source, got %T", source)
+	}
+	dorisSink, ok := sink.(*dorisSyncService)
+	if !ok {
+		return nil, fmt.Errorf("planning requires a doris destination, got %T", sink)
+	}
+
+	workerConfig.SetDefaults()
+
+	return &planService{
+		source:       postgresSource,
+		sink:         dorisSink,
+		workerConfig: workerConfig,
+	}, nil
+}
+
+// Plan discovers the selected tables and estimates their size from the catalog statistics.
+// Source tables mapped to the same Doris table are reported in the returned error along with the plans.
+func (p *planService) Plan(ctx context.Context) ([]dtos.TablePlan, error) {
+	tables, err := p.source.discoverTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []dtos.TablePlan
+	for _, table := range tables {
+		reltuples, totalBytes, err := p.source.repo.GetTableEstimate(ctx, table.TableSchema, table.TableName)
+		if err != nil {
+			return nil, err
+		}
+
+		plan := dtos.TablePlan{
+			Schema:      table.TableSchema,
+			Table:       table.TableName,
+			TargetTable: p.sink.target(table).String(),
+			KeyStrategy: table.KeyStrategy,
+			KeyColumns:  lo.Map(table.PrimaryKeys, func(key dtos.PrimaryKey, _ int) string { return key.ColumnName }),
+			KeyIndex:    table.KeyIndex,
+			Analyzed:    reltuples >= 0,
+			TotalBytes:  totalBytes,
+		}
+		if column, ok := p.source.configuration.GetIncrementalColumn(table.TableSchema, table.TableName); ok {
+			plan.IncrementalColumn = column
+		}
+		if predicate, ok := p.source.configuration.GetRowFilter(table.TableSchema, table.TableName); ok {
+			plan.RowFilter = predicate.Clause
+		}
+		if plan.Analyzed {
+			plan.EstimatedRows = int64(reltuples)
+			plan.KeyRanges = ceilDiv(plan.EstimatedRows, int64(p.workerConfig.WorkerBatchSize))
+			plan.StreamLoads = ceilDiv(plan.EstimatedRows, int64(p.workerConfig.RecordBatchSize))
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, p.sink.targetCollisions(tables)
+}
+
+// Write prints the plans as an aligned text table or as JSON
+func (p *planService) Write(w io.Writer, plans []dtos.TablePlan, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plans)
+	case "text":
+	default:
+		return fmt.Errorf("unknown plan format %q, expected text or json", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tTARGET\tSTRATEGY\tKEY\tROWS\tSIZE\tKEY RANGES\tSTREAM LOADS")
+
+	var totalRows, totalBytes, totalRanges, totalLoads int64
+	for _, plan := range plans {
+		key := strings.Join(plan.KeyColumns, ", ")
+		if plan.KeyIndex != "" {
+			key = fmt.Sprintf("%s (%s)", plan.KeyIndex, key)
+		}
+		if plan.IncrementalColumn != "" {
+			key += fmt.Sprintf(" [incremental on %s]", plan.IncrementalColumn)
+		}
+		if plan.RowFilter != "" {
+			key += fmt.Sprintf(" [filtered by %s]", plan.RowFilter)
+		}
+
+		rows, ranges, loads := "unknown", "unknown", "unknown"
+		if plan.Analyzed {
+			rows = fmt.Sprintf("~%d", plan.EstimatedRows)
+			ranges = fmt.Sprintf("%d", plan.KeyRanges)
+			loads = fmt.Sprintf("%d", plan.StreamLoads)
+		}
+
+		fmt.Fprintf(tw, "%s.%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			plan.Schema, plan.Table, plan.TargetTable, plan.KeyStrategy, key, rows, formatBytes(plan.TotalBytes), ranges, loads)
+
+		totalRows += plan.EstimatedRows
+		totalBytes += plan.TotalBytes
+		totalRanges += plan.KeyRanges
+		totalLoads += plan.StreamLoads
+	}
+
+	fmt.Fprintf(tw, "TOTAL (%d tables)\t\t\t\t~%d\t%s\t%d\t%d\n", len(plans), totalRows, formatBytes(totalBytes), totalRanges, totalLoads)
+	if lo.SomeBy(plans, func(plan dtos.TablePlan) bool { return !plan.Analyzed }) {
+		fmt.Fprintln(tw, "Tables marked unknown were never analyzed, run ANALYZE on them for row estimates")
+	}
+
+	return tw.Flush()
+}
+
+func ceilDiv(a int64, b int64) int64 {
+	if b <= 0 {
+		return 0
+	}
+	return (a + b - 1) / b
+}
+
+// formatBytes renders a size with a binary unit
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
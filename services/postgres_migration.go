@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"migration-tool-go/config"
 	"migration-tool-go/dtos"
 	"migration-tool-go/dtos/common"
@@ -10,101 +11,324 @@ import (
 	"migration-tool-go/repository"
 	"sync"
 	"time"
+
+	"github.com/samber/lo"
 )
 
-var PostgresMigration = &postgresMigration{}
+// minCtidRangeScanVersion is the server_version_num of PostgreSQL 14, the first release with TID range scans
+const minCtidRangeScanVersion = 140000
+
+// defaultRowsPerBlock sizes ctid ranges of tables without statistics
+const defaultRowsPerBlock = 100
 
 type postgresMigration struct {
-	configuration postgres.Configuration
-	workerConfig  common.WorkerConfiguration
-	repo          *repository.Repo
+	connectionDetails postgres.ConnectionDetails
+	configuration     postgres.Configuration
+	workerConfig      common.WorkerConfiguration
+	repo              *repository.Repo
+	tableFilter       *tableFilter
+	// serverVersion is the server_version_num of the source, read when it connects
+	serverVersion int
+}
+
+func init() {
+	RegisterSource("postgres", config.DecodeValue[postgres.Postgres], NewPostgresMigration)
 }
 
-//type TableInfoChan struct {
-//	TableInfo          dtos.TableInfo
-//	PrimaryKeyRange    chan dtos.PrimaryKeyRange
-//	RecordsChan        chan map[string]any
-//	TotalUuidsRead     *int
-//	TotalRecordsRead   *int
-//	ReadingIdsDone     *bool
-//	ReadingRecordsDone *bool
-//}
-
-func NewPostgresMigration(source common.Source[any], workerConfig common.WorkerConfiguration) {
-	PostgresMigration = &postgresMigration{
-		configuration: source.Value.(postgres.Postgres).Configuration,
-		workerConfig:  workerConfig,
-		repo:          repository.NewRepo(config.NewConnection(source.Value.(postgres.Postgres), workerConfig.NoOfWorkers)),
+// NewPostgresMigration creates the PostgreSQL source and connects to the database
+func NewPostgresMigration(source common.Source[any], workerConfig common.WorkerConfiguration) (Source, error) {
+	postgresSource, ok := source.Value.(postgres.Postgres)
+	if !ok {
+		return nil, fmt.Errorf("invalid postgres source configuration of type %T", source.Value)
 	}
+
+	filter, err := newTableFilter(postgresSource.Configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	converters, err := repository.NewConverters(postgresSource.Configuration.Conversion)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := repository.NewRepo(config.NewConnection(postgresSource, workerConfig.NoOfWorkers), converters)
+	serverVersion, err := repo.GetServerVersionNum(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgresMigration{
+		connectionDetails: postgresSource.ConnectionDetails,
+		configuration:     postgresSource.Configuration,
+		workerConfig:      workerConfig,
+		repo:              repo,
+		tableFilter:       filter,
+		serverVersion:     serverVersion,
+	}, nil
 }
 
-func (p postgresMigration) GetRecordsFromSource(ctx context.Context, tableInfoChan chan *dtos.TableInfoChan, processedAllTables *bool) error {
+// DiscoverTables returns the tables of the configured schemas, incremental tables without new rows are left out
+func (p postgresMigration) DiscoverTables(ctx context.Context) ([]dtos.TableInfo, error) {
+	tableInfoList, err := p.discoverTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []dtos.TableInfo
+	for _, tableInfo := range tableInfoList {
+		column, ok := p.configuration.GetIncrementalColumn(tableInfo.TableSchema, tableInfo.TableName)
+		if !ok {
+			selected = append(selected, tableInfo)
+			continue
+		}
+
+		predicate, hasRows, err := p.incrementalPredicate(ctx, tableInfo, column)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute incremental window of %s.%s: %w", tableInfo.TableSchema, tableInfo.TableName, err)
+		}
+		if !hasRows {
+			logger.Sugar.Infof("Table %s.%s has no rows above its watermark, skipping", tableInfo.TableSchema, tableInfo.TableName)
+			continue
+		}
+
+		tableInfo.Predicates = append(tableInfo.Predicates, predicate)
+		selected = append(selected, tableInfo)
+	}
+
+	return selected, nil
+}
+
+// discoverTables returns the metadata of every table of the configured schemas selected by the table filter,
+// without the columns left out by their projection or dropped by the transforms, and restricted by their row filter
+func (p postgresMigration) discoverTables(ctx context.Context) ([]dtos.TableInfo, error) {
 	var schemas []any
 
 	for _, schema := range p.configuration.Schemas {
+		if p.tableFilter.SchemaExcluded(schema) {
+			logger.Sugar.Infof("Skipping schema %s: schema is excluded", schema)
+			continue
+		}
 		schemas = append(schemas, schema)
 	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("every configured schema is excluded")
+	}
 
 	tableInfoList, err := p.repo.GetTableInfo(ctx, schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []dtos.TableInfo
+	for _, tableInfo := range tableInfoList {
+		ok, reason := p.tableFilter.Select(tableInfo.TableSchema, tableInfo.TableName)
+		if !ok {
+			logger.Sugar.Infof("Skipping table %s.%s: %s", tableInfo.TableSchema, tableInfo.TableName, reason)
+			continue
+		}
+		logger.Sugar.Infof("Selected table %s.%s: %s", tableInfo.TableSchema, tableInfo.TableName, reason)
+
+		if err := p.resolveKeyStrategy(ctx, &tableInfo); err != nil {
+			return nil, err
+		}
+		if err := p.applyProjection(&tableInfo); err != nil {
+			return nil, err
+		}
+		// Dropped columns are neither extracted nor loaded
+		tableInfo, err = TransformService.Table(tableInfo)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.applyRowFilter(ctx, &tableInfo); err != nil {
+			return nil, err
+		}
+		selected = append(selected, tableInfo)
+	}
+
+	logger.Sugar.Infof("Selected %d of %d tables", len(selected), len(tableInfoList))
+	return selected, nil
+}
+
+// resolveKeyStrategy picks how a table is split into key ranges: by its primary key, by its best NOT NULL
+// unique index, or by ctid block ranges when it has neither
+func (p postgresMigration) resolveKeyStrategy(ctx context.Context, tableInfo *dtos.TableInfo) error {
+	if len(tableInfo.PrimaryKeys) > 0 {
+		tableInfo.KeyStrategy = dtos.KeyStrategyPrimaryKey
+		return nil
+	}
 
+	indexName, indexColumns, err := p.repo.GetUniqueIndexKey(ctx, tableInfo.TableSchema, tableInfo.TableName)
 	if err != nil {
 		return err
 	}
+	if indexName == "" {
+		tableInfo.KeyStrategy = dtos.KeyStrategyCtid
+		logger.Sugar.Warnf("Table %s.%s has no primary key nor NOT NULL unique index, it is scanned by ctid block ranges", tableInfo.TableSchema, tableInfo.TableName)
+		if p.serverVersion < minCtidRangeScanVersion {
+			logger.Sugar.Warnf("PostgreSQL %d has no TID range scans, every ctid block range of %s.%s reads the whole table, add a primary key or upgrade to PostgreSQL 14", p.serverVersion, tableInfo.TableSchema, tableInfo.TableName)
+		}
+		return nil
+	}
 
-	wg := sync.WaitGroup{}
+	columns := lo.SliceToMap(tableInfo.Columns, func(column dtos.ColumnInfo) (string, dtos.ColumnInfo) {
+		return column.Name, column
+	})
+	for _, column := range indexColumns {
+		tableInfo.PrimaryKeys = append(tableInfo.PrimaryKeys, dtos.PrimaryKey{ColumnName: column, DataType: columns[column].DataType})
+	}
+	tableInfo.KeyStrategy = dtos.KeyStrategyUniqueIndex
+	tableInfo.KeyIndex = indexName
+	logger.Sugar.Infof("Table %s.%s has no primary key, paginating by unique index %s %v", tableInfo.TableSchema, tableInfo.TableName, indexName, indexColumns)
 
-	concurrentTables := make(chan bool, p.workerConfig.ConcurrentTables)
+	return nil
+}
 
-	for _, tableInfo := range tableInfoList {
-		infoChan := dtos.NewTableInfoChan(tableInfo, p.workerConfig.WorkerBatchSize, p.workerConfig.IdBatchSize)
-		tableInfoChan <- infoChan
-		concurrentTables <- true
+// applyProjection leaves out the columns of the table its projection does not extract. The key columns
+// paginate the table and identify its rows in Doris, they cannot be left out.
+func (p postgresMigration) applyProjection(tableInfo *dtos.TableInfo) error {
+	projection, ok := p.configuration.GetProjection(tableInfo.TableSchema, tableInfo.TableName)
+	if !ok {
+		return nil
+	}
+	if len(projection.IncludeColumns) > 0 && len(projection.ExcludeColumns) > 0 {
+		return fmt.Errorf("projection of %s.%s sets both include_columns and exclude_columns", tableInfo.TableSchema, tableInfo.TableName)
+	}
 
-		wg.Add(1)
-		go p.processTable(ctx, infoChan, concurrentTables, &wg)
+	for _, name := range append(append([]string{}, projection.IncludeColumns...), projection.ExcludeColumns...) {
+		if !lo.ContainsBy(tableInfo.Columns, func(column dtos.ColumnInfo) bool { return column.Name == name }) {
+			return fmt.Errorf("projected column %s does not exist in %s.%s", name, tableInfo.TableSchema, tableInfo.TableName)
+		}
+	}
+	keyColumns := lo.Map(tableInfo.PrimaryKeys, func(key dtos.PrimaryKey, _ int) string { return key.ColumnName })
+	if excluded := lo.Intersect(keyColumns, projection.ExcludeColumns); len(excluded) > 0 {
+		return fmt.Errorf("key column %s of %s.%s cannot be excluded", excluded[0], tableInfo.TableSchema, tableInfo.TableName)
 	}
 
-	defer close(concurrentTables)
+	columns := tableInfo.Columns
+	tableInfo.Columns = lo.Filter(tableInfo.Columns, func(column dtos.ColumnInfo, _ int) bool {
+		if lo.Contains(keyColumns, column.Name) {
+			return true
+		}
+		if len(projection.IncludeColumns) > 0 {
+			return lo.Contains(projection.IncludeColumns, column.Name)
+		}
+		return !lo.Contains(projection.ExcludeColumns, column.Name)
+	})
 
-	wg.Wait()
+	logger.Sugar.Infof("Extracting %d of %d columns of %s.%s", len(tableInfo.Columns), len(columns), tableInfo.TableSchema, tableInfo.TableName)
+	return nil
+}
+
+// applyRowFilter restricts the table to the rows matching its row filter, which is checked against the
+// table first so a mistyped filter fails before any row is read
+func (p postgresMigration) applyRowFilter(ctx context.Context, tableInfo *dtos.TableInfo) error {
+	predicate, ok := p.configuration.GetRowFilter(tableInfo.TableSchema, tableInfo.TableName)
+	if !ok {
+		return nil
+	}
 
-	*processedAllTables = true
+	if err := p.repo.CheckPredicates(ctx, tableInfo.TableSchema, tableInfo.TableName, []dtos.Predicate{predicate}); err != nil {
+		return fmt.Errorf("invalid row filter %q of %s.%s: %w", predicate.Clause, tableInfo.TableSchema, tableInfo.TableName, err)
+	}
 
+	logger.Sugar.Infof("Table %s.%s is filtered by %s", tableInfo.TableSchema, tableInfo.TableName, predicate.Clause)
+	tableInfo.Predicates = append(tableInfo.Predicates, predicate)
 	return nil
 }
 
-func (p postgresMigration) processTable(ctx context.Context, tableInfoChan *dtos.TableInfoChan, concurrentTables chan bool, wg *sync.WaitGroup) {
+// incrementalPredicate bounds the extraction of a table to the rows between its stored watermark and
+// the current maximum of the watermark column. The upper bound is fixed when the window starts so that
+// rows written during the run are picked up by the next one.
+func (p postgresMigration) incrementalPredicate(ctx context.Context, tableInfo dtos.TableInfo, column string) (dtos.Predicate, bool, error) {
+	checkpoint, _ := CheckpointService.Get(tableInfo.TableSchema, tableInfo.TableName)
+
+	var lowerBound []dtos.Predicate
+	if checkpoint.Watermark != nil {
+		lowerBound = append(lowerBound, dtos.Predicate{Clause: fmt.Sprintf("%s > $1", column), Args: []any{checkpoint.Watermark}})
+	}
+
+	upper := checkpoint.PendingWatermark
+	if upper == nil {
+		var err error
+		upper, err = p.repo.GetMaxValue(ctx, tableInfo.TableSchema, tableInfo.TableName, column, lowerBound)
+		if err != nil {
+			return dtos.Predicate{}, false, err
+		}
+		if upper == nil {
+			return dtos.Predicate{}, false, nil
+		}
+
+		if err := CheckpointService.SetPendingWatermark(tableInfo.TableSchema, tableInfo.TableName, upper); err != nil {
+			return dtos.Predicate{}, false, err
+		}
+	}
+
+	logger.Sugar.Infof("Incremental sync of %s.%s on column %s, watermark: %v, upper bound: %v", tableInfo.TableSchema, tableInfo.TableName, column, checkpoint.Watermark, upper)
+
+	if checkpoint.Watermark == nil {
+		return dtos.Predicate{Clause: fmt.Sprintf("%s <= $1", column), Args: []any{upper}}, true, nil
+	}
+	return dtos.Predicate{Clause: fmt.Sprintf("%s > $1 AND %s <= $2", column, column), Args: []any{checkpoint.Watermark, upper}}, true, nil
+}
+
+// PlanKeyRanges walks the key of the table and publishes worker sized key ranges
+func (p postgresMigration) PlanKeyRanges(ctx context.Context, tableInfoChan *dtos.TableInfoChan) error {
+
+	checkpoint := tableInfoChan.Checkpoint
+	if checkpoint != nil && checkpoint.HasLastKey() && checkpoint.KeyStrategy != "" && checkpoint.KeyStrategy != tableInfoChan.TableInfo.KeyStrategy {
+		logger.Sugar.Warnf("Table %s.%s was checkpointed with the %s strategy but now uses %s, restarting it from the beginning", tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, checkpoint.KeyStrategy, tableInfoChan.TableInfo.KeyStrategy)
+		checkpoint = nil
+	}
+
+	// ctid tables are never resumed, the runner skips them or they restart from their first block
+	if tableInfoChan.TableInfo.KeyStrategy == dtos.KeyStrategyCtid {
+		return p.getCtidRanges(ctx, p.workerConfig.WorkerBatchSize, tableInfoChan)
+	}
 
 	if len(tableInfoChan.TableInfo.PrimaryKeys) == 0 {
-		logger.Sugar.Errorf("Table %s.%s has no primary key", tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName)
-		return
+		return fmt.Errorf("table %s.%s has no key columns", tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName)
+	}
+
+	// Continue after the last committed key range when resuming
+	if checkpoint != nil && checkpoint.HasLastKey() {
+		logger.Sugar.Infof("Resuming table %s.%s after the last committed key", tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName)
+
+		if len(tableInfoChan.TableInfo.PrimaryKeys) > 1 {
+			return p.getMultiPrimaryKeyRange(ctx, checkpoint.LastIds, false, p.workerConfig.IdBatchSize, p.workerConfig.WorkerBatchSize, tableInfoChan)
+		}
+		return p.getPrimaryKeyRange(ctx, checkpoint.LastId, false, tableInfoChan.TableInfo.PrimaryKeys[0].ColumnName, p.workerConfig.IdBatchSize, p.workerConfig.WorkerBatchSize, tableInfoChan)
 	}
 
 	if len(tableInfoChan.TableInfo.PrimaryKeys) > 1 {
-		firstIds, err := p.repo.GetFirstIdsByMultiPrimaryKeys(ctx, tableInfoChan.TableInfo.Columns, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, tableInfoChan.TableInfo.PrimaryKeys)
+		firstIds, err := p.repo.GetFirstIdsByMultiPrimaryKeys(ctx, tableInfoChan.TableInfo.Columns, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, tableInfoChan.TableInfo.PrimaryKeys, tableInfoChan.TableInfo.Predicates)
 
 		if err != nil {
-			logger.Sugar.Errorf("Failed to fetch first primary key: %v", err)
-			return
+			return fmt.Errorf("failed to fetch first primary key: %w", err)
+		}
+		if firstIds == nil {
+			return nil
 		}
 
-		go p.getMultiPrimaryKeyRange(ctx, firstIds, true, p.workerConfig.IdBatchSize, p.workerConfig.WorkerBatchSize, tableInfoChan)
+		return p.getMultiPrimaryKeyRange(ctx, firstIds, true, p.workerConfig.IdBatchSize, p.workerConfig.WorkerBatchSize, tableInfoChan)
 
 	} else {
-		firstId, err := p.repo.GetFirstIdByPrimaryKey(ctx, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, tableInfoChan.TableInfo.PrimaryKeys[0].ColumnName)
+		firstId, err := p.repo.GetFirstIdByPrimaryKey(ctx, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, tableInfoChan.TableInfo.PrimaryKeys[0].ColumnName, tableInfoChan.TableInfo.Predicates)
 
 		if err != nil {
-			logger.Sugar.Errorf("Failed to fetch first primary key: %v", err)
-			return
+			return fmt.Errorf("failed to fetch first primary key: %w", err)
+		}
+		if firstId == nil {
+			return nil
 		}
 
-		go p.getPrimaryKeyRange(ctx, firstId, true, tableInfoChan.TableInfo.PrimaryKeys[0].ColumnName, p.workerConfig.IdBatchSize, p.workerConfig.WorkerBatchSize, tableInfoChan)
+		return p.getPrimaryKeyRange(ctx, firstId, true, tableInfoChan.TableInfo.PrimaryKeys[0].ColumnName, p.workerConfig.IdBatchSize, p.workerConfig.WorkerBatchSize, tableInfoChan)
 	}
+}
 
-	p.getRecordsFromPrimaryKeyRange(ctx, tableInfoChan)
-
-	<-concurrentTables
-	wg.Done()
+// StreamRecords fetches the records of every published key range
+func (p postgresMigration) StreamRecords(ctx context.Context, infoChan *dtos.TableInfoChan) {
+	p.getRecordsFromPrimaryKeyRange(ctx, infoChan)
 }
 
 func (p postgresMigration) getRecordsFromPrimaryKeyRange(ctx context.Context, infoChan *dtos.TableInfoChan) {
@@ -120,37 +344,57 @@ func (p postgresMigration) getRecordsFromPrimaryKeyRange(ctx context.Context, in
 			go func(wgIn *sync.WaitGroup, parallelProcessingChanIn chan bool) {
 				switch primaryKeyRange.Type {
 				case "id_range":
-					records, err := p.repo.GetRecordsById(ctx, infoChan.TableInfo.Columns, infoChan.TableInfo.PrimaryKeys[0].ColumnName, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, primaryKeyRange.IdRange[0], primaryKeyRange.IdRange[1])
+					records, err := p.repo.GetRecordsById(ctx, infoChan.TableInfo.Columns, infoChan.TableInfo.PrimaryKeys[0].ColumnName, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, primaryKeyRange.IdRange[0], primaryKeyRange.IdRange[1], infoChan.TableInfo.Predicates)
 
 					if err != nil {
-						logger.Sugar.Errorf("Failed to fetch records by uuids: %v", err)
+						logger.Sugar.Errorf("Failed to fetch records by id range %v of %s.%s: %v", primaryKeyRange.IdRange, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, err)
+						infoChan.IncrementFailedRanges(primaryKeyRange.Keys)
 					} else {
 						//uuidStr := uuid.New().String()
 						//utils.ConvertRecordsToJSON(records, fmt.Sprintf("records_json/%s_debug.json", uuidStr), true)
 						//
 						//utils.ConvertRecordsToJSON([]any{primaryKeyRange.IdRange}, fmt.Sprintf("uuid_range_json/%s_debug.json", uuidStr), true)
 
+						infoChan.Ranges.SetRecordCount(primaryKeyRange.Seq, uint64(len(records)))
 						for _, record := range records {
-							infoChan.RecordsChan <- record
+							infoChan.RecordsChan <- dtos.Record{RangeSeq: primaryKeyRange.Seq, Values: record}
 						}
 
 						infoChan.IncrementTotalRecordsRead(uint64(len(records)))
 					}
 
 				case "multi_key":
-					records, err := p.repo.GetRecordsByMultiPrimaryKeys(ctx, infoChan.TableInfo.Columns, infoChan.TableInfo.PrimaryKeys, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, primaryKeyRange.MultiKeyRange[0], primaryKeyRange.MultiKeyRange[1])
+					records, err := p.repo.GetRecordsByMultiPrimaryKeys(ctx, infoChan.TableInfo.Columns, infoChan.TableInfo.PrimaryKeys, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, primaryKeyRange.MultiKeyRange[0], primaryKeyRange.MultiKeyRange[1], infoChan.TableInfo.Predicates)
 
 					if err != nil {
-						logger.Sugar.Errorf("Failed to fetch records by multi primary keys: %v", err)
-
+						logger.Sugar.Errorf("Failed to fetch records by multi key range %v of %s.%s: %v", primaryKeyRange.MultiKeyRange, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, err)
+						infoChan.IncrementFailedRanges(primaryKeyRange.Keys)
 					} else {
+						infoChan.Ranges.SetRecordCount(primaryKeyRange.Seq, uint64(len(records)))
 						for _, record := range records {
-							infoChan.RecordsChan <- record
+							infoChan.RecordsChan <- dtos.Record{RangeSeq: primaryKeyRange.Seq, Values: record}
 						}
 
 						infoChan.IncrementTotalRecordsRead(uint64(len(records)))
 					}
 
+				case "ctid_range":
+					records, err := p.repo.GetRecordsByCtidRange(ctx, infoChan.TableInfo.Columns, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, primaryKeyRange.IdRange[0], primaryKeyRange.IdRange[1], infoChan.TableInfo.Predicates)
+
+					if err != nil {
+						// The rows of the blocks are unknown, the range stays unfetched so the table cannot complete
+						logger.Sugar.Errorf("Failed to fetch records by ctid range %v of %s.%s: %v", primaryKeyRange.IdRange, infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName, err)
+						infoChan.IncrementFailedRanges(0)
+					} else {
+						// The rows of a block range are only known once read, count them as planned and read together
+						infoChan.IncrementTotalUuidsRead(uint64(len(records)))
+						infoChan.Ranges.SetRecordCount(primaryKeyRange.Seq, uint64(len(records)))
+						for _, record := range records {
+							infoChan.RecordsChan <- dtos.Record{RangeSeq: primaryKeyRange.Seq, Values: record}
+						}
+
+						infoChan.IncrementTotalRecordsRead(uint64(len(records)))
+					}
 				}
 
 				<-parallelProcessingChanIn
@@ -164,7 +408,8 @@ func (p postgresMigration) getRecordsFromPrimaryKeyRange(ctx context.Context, in
 					logger.Sugar.Infof("Finished reading the ids %s.%s", infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName)
 				}
 
-				if infoChan.GetTotalUuidsRead() == infoChan.GetTotalRecordsRead() {
+				// Every planned key must have been read or failed with its range, ctid ranges only count their rows once read
+				if len(infoChan.PrimaryKeyRange) == 0 && len(parallelProcessingChan) == 0 && infoChan.GetTotalUuidsRead() == infoChan.GetTotalRecordsRead()+infoChan.GetFailedKeys() {
 					infoChan.ReadingRecordsDone.Store(true)
 					logger.Sugar.Infof("Finished reading the records %s.%s", infoChan.TableInfo.TableSchema, infoChan.TableInfo.TableName)
 					processingDone = true
@@ -182,15 +427,15 @@ func (p postgresMigration) getRecordsFromPrimaryKeyRange(ctx context.Context, in
 	wg.Wait()
 }
 
-func (p postgresMigration) getPrimaryKeyRange(ctx context.Context, lastId any, includeLastId bool, primaryKey string, idBatchSize int, workerBatchSize int, tableInfoChan *dtos.TableInfoChan) {
+// getPrimaryKeyRange walks the key from lastId and publishes its ranges, a failed batch stops the walk with its error
+func (p postgresMigration) getPrimaryKeyRange(ctx context.Context, lastId any, includeLastId bool, primaryKey string, idBatchSize int, workerBatchSize int, tableInfoChan *dtos.TableInfoChan) error {
 
 	for {
 
-		ids, err := p.repo.FetchBatchPrimaryKeys(ctx, lastId, includeLastId, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, primaryKey, idBatchSize)
+		ids, err := p.repo.FetchBatchPrimaryKeys(ctx, lastId, includeLastId, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, primaryKey, idBatchSize, tableInfoChan.TableInfo.Predicates)
 
 		if err != nil {
-			logger.Sugar.Errorf("Failed to fetch primary key batch: %v", err)
-			return
+			return fmt.Errorf("failed to fetch primary key batch after %v: %w", lastId, err)
 		}
 
 		if includeLastId {
@@ -199,7 +444,7 @@ func (p postgresMigration) getPrimaryKeyRange(ctx context.Context, lastId any, i
 
 		if len(ids) == 0 {
 			tableInfoChan.ReadingIdsDone.Store(true)
-			break
+			return nil
 		}
 
 		//utils.ConvertRecordsToJSON(ids, fmt.Sprintf("uuids_json/%s_debug.json", uuid.New().String()), true)
@@ -211,7 +456,7 @@ func (p postgresMigration) getPrimaryKeyRange(ctx context.Context, lastId any, i
 				end = len(ids)
 			}
 
-			tableInfoChan.PrimaryKeyRange <- dtos.PrimaryKeyRange{Type: "id_range", IdRange: [2]any{ids[i], ids[end-1]}}
+			tableInfoChan.PrimaryKeyRange <- tableInfoChan.Ranges.Register(dtos.PrimaryKeyRange{Type: "id_range", IdRange: [2]any{ids[i], ids[end-1]}, Keys: uint64(end - i)})
 
 			//switch tableInfo.PrimaryKeys[0].DataType {
 			//case "uuid":
@@ -228,14 +473,15 @@ func (p postgresMigration) getPrimaryKeyRange(ctx context.Context, lastId any, i
 	}
 }
 
-func (p postgresMigration) getMultiPrimaryKeyRange(ctx context.Context, lastIds map[string]any, includeLastId bool, idBatchSize int, workerBatchSize int, tableInfoChan *dtos.TableInfoChan) {
+// getMultiPrimaryKeyRange walks the composite key from lastIds and publishes its ranges, a failed batch stops
+// the walk with its error
+func (p postgresMigration) getMultiPrimaryKeyRange(ctx context.Context, lastIds map[string]any, includeLastId bool, idBatchSize int, workerBatchSize int, tableInfoChan *dtos.TableInfoChan) error {
 	for {
 
-		ids, err := p.repo.FetchBatchMultiPrimaryKeys(ctx, lastIds, includeLastId, tableInfoChan.TableInfo.Columns, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, tableInfoChan.TableInfo.PrimaryKeys, idBatchSize)
+		ids, err := p.repo.FetchBatchMultiPrimaryKeys(ctx, lastIds, includeLastId, tableInfoChan.TableInfo.Columns, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, tableInfoChan.TableInfo.PrimaryKeys, idBatchSize, tableInfoChan.TableInfo.Predicates)
 
 		if err != nil {
-			logger.Sugar.Errorf("Failed to fetch multi primary keys batch: %v", err)
-			return
+			return fmt.Errorf("failed to fetch multi primary keys batch after %v: %w", lastIds, err)
 		}
 
 		if includeLastId {
@@ -244,7 +490,7 @@ func (p postgresMigration) getMultiPrimaryKeyRange(ctx context.Context, lastIds
 
 		if len(ids) == 0 {
 			tableInfoChan.ReadingIdsDone.Store(true)
-			break
+			return nil
 		}
 
 		// Divide into batches of 10K (first & last UUID)
@@ -254,7 +500,7 @@ func (p postgresMigration) getMultiPrimaryKeyRange(ctx context.Context, lastIds
 				end = len(ids)
 			}
 
-			tableInfoChan.PrimaryKeyRange <- dtos.PrimaryKeyRange{Type: "multi_key", MultiKeyRange: [2]map[string]any{ids[i], ids[end-1]}}
+			tableInfoChan.PrimaryKeyRange <- tableInfoChan.Ranges.Register(dtos.PrimaryKeyRange{Type: "multi_key", MultiKeyRange: [2]map[string]any{ids[i], ids[end-1]}, Keys: uint64(end - i)})
 		}
 
 		tableInfoChan.IncrementTotalUuidsRead(uint64(len(ids)))
@@ -262,5 +508,34 @@ func (p postgresMigration) getMultiPrimaryKeyRange(ctx context.Context, lastIds
 		// Update lastUUID for next iteration
 		lastIds = ids[len(ids)-1]
 	}
+}
+
+// getCtidRanges splits the heap of a table into block ranges holding about workerBatchSize rows each.
+// The last range is open ended so rows appended to new blocks during the run are read too.
+func (p postgresMigration) getCtidRanges(ctx context.Context, workerBatchSize int, tableInfoChan *dtos.TableInfoChan) error {
+	blocks, reltuples, err := p.repo.GetRelationBlocks(ctx, tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName)
+	if err != nil {
+		return err
+	}
 
+	// Tables that were never analyzed have no row estimate
+	rowsPerBlock := float64(defaultRowsPerBlock)
+	if blocks > 0 && reltuples > 0 {
+		rowsPerBlock = reltuples / float64(blocks)
+	}
+	blocksPerRange := max(int64(float64(workerBatchSize)/rowsPerBlock), 1)
+
+	logger.Sugar.Infof("Scanning %s.%s by ctid, %d blocks, %d blocks per range", tableInfoChan.TableInfo.TableSchema, tableInfoChan.TableInfo.TableName, blocks, blocksPerRange)
+
+	for start := int64(0); ; start += blocksPerRange {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if start+blocksPerRange >= blocks {
+			tableInfoChan.PrimaryKeyRange <- tableInfoChan.Ranges.Register(dtos.PrimaryKeyRange{Type: "ctid_range", IdRange: [2]any{start, nil}})
+			return nil
+		}
+		tableInfoChan.PrimaryKeyRange <- tableInfoChan.Ranges.Register(dtos.PrimaryKeyRange{Type: "ctid_range", IdRange: [2]any{start, start + blocksPerRange}})
+	}
 }
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"migration-tool-go/config"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/common"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrLabelAlreadyExists is returned when the label of a batch was already used by an earlier committed load
+	ErrLabelAlreadyExists = errors.New("load label already exists")
+
+	// ErrRowsRejected is returned along with the number of loaded rows when the destination
+	// committed a batch without some of its rows
+	ErrRowsRejected = errors.New("rows rejected by the destination")
+)
+
+// Sink is implemented by every destination the migration runner can load data into
+type Sink interface {
+	// PrepareTable is called once before the first batch of a table is written
+	PrepareTable(ctx context.Context, table dtos.TableInfo) error
+
+	// WriteBatch loads a batch of records under a unique label and returns the number of rows loaded.
+	// A batch committed without some of its rows returns the loaded count and ErrRowsRejected.
+	WriteBatch(ctx context.Context, table dtos.TableInfo, records []map[string]any, label string) (uint64, error)
+
+	// FinalizeTable is called once every record of a table has been processed.
+	// succeeded is false when the table had failed batches or the run was cancelled.
+	FinalizeTable(ctx context.Context, table dtos.TableInfo, succeeded bool) error
+
+	// Close releases the resources held by the sink
+	Close() error
+}
+
+// DeferredCommitSink is implemented by sinks that can hold the batches of a table until FinalizeTable commits them
+type DeferredCommitSink interface {
+	// CommitsOnFinalize reports whether the batches of the table only become visible once FinalizeTable succeeds
+	CommitsOnFinalize(table dtos.TableInfo) bool
+}
+
+// commitsOnFinalize reports whether the sink defers the batches of the table to FinalizeTable
+func commitsOnFinalize(sink Sink, table dtos.TableInfo) bool {
+	deferredSink, ok := sink.(DeferredCommitSink)
+	return ok && deferredSink.CommitsOnFinalize(table)
+}
+
+// SinkFactory builds a Sink from its parsed configuration
+type SinkFactory func(destination common.Destination[any]) (Sink, error)
+
+// sinkEngine is a registered destination type, the decoder of its configuration and its factory
+type sinkEngine struct {
+	decoder config.ValueDecoder
+	factory SinkFactory
+}
+
+var (
+	sinkEnginesMu sync.RWMutex
+	sinkEngines   = make(map[string]sinkEngine)
+)
+
+// RegisterSink makes a destination available under the given config type, decoder parses its "value" block
+func RegisterSink(destinationType string, decoder config.ValueDecoder, factory SinkFactory) {
+	sinkEnginesMu.Lock()
+	defer sinkEnginesMu.Unlock()
+
+	if decoder == nil || factory == nil {
+		panic("services: RegisterSink decoder or factory is nil")
+	}
+	if _, exists := sinkEngines[destinationType]; exists {
+		panic("services: RegisterSink called twice for destination type " + destinationType)
+	}
+	sinkEngines[destinationType] = sinkEngine{decoder: decoder, factory: factory}
+}
+
+// SinkDecoder returns the configuration decoder registered for a destination type, it is a config.DecoderLookup
+func SinkDecoder(destinationType string) (config.ValueDecoder, bool) {
+	sinkEnginesMu.RLock()
+	defer sinkEnginesMu.RUnlock()
+
+	engine, ok := sinkEngines[destinationType]
+	return engine.decoder, ok
+}
+
+// NewSink builds the sink registered for destination.Type
+func NewSink(destination common.Destination[any]) (Sink, error) {
+	sinkEnginesMu.RLock()
+	engine, ok := sinkEngines[destination.Type]
+	sinkEnginesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported destination type %q, registered types: %v", destination.Type, RegisteredSinks())
+	}
+
+	return engine.factory(destination)
+}
+
+// RegisteredSinks returns the sorted list of registered destination types
+func RegisteredSinks() []string {
+	sinkEnginesMu.RLock()
+	defer sinkEnginesMu.RUnlock()
+
+	var types []string
+	for destinationType := range sinkEngines {
+		types = append(types, destinationType)
+	}
+	sort.Strings(types)
+	return types
+}
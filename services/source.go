@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"migration-tool-go/config"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/common"
+	"sort"
+	"sync"
+)
+
+// Source is implemented by every engine the migration runner can extract data from
+type Source interface {
+	// DiscoverTables returns the tables selected for migration
+	DiscoverTables(ctx context.Context) ([]dtos.TableInfo, error)
+
+	// PlanKeyRanges splits a table into key ranges and publishes them on infoChan.PrimaryKeyRange.
+	// The runner marks infoChan.ReadingIdsDone once it returns.
+	PlanKeyRanges(ctx context.Context, infoChan *dtos.TableInfoChan) error
+
+	// StreamRecords reads the records of every planned key range into infoChan.RecordsChan
+	// and marks infoChan.ReadingRecordsDone when the table has been fully read.
+	StreamRecords(ctx context.Context, infoChan *dtos.TableInfoChan)
+}
+
+// SourceFactory builds a Source from its parsed configuration
+type SourceFactory func(source common.Source[any], workerConfig common.WorkerConfiguration) (Source, error)
+
+// sourceEngine is a registered source type, the decoder of its configuration and its factory
+type sourceEngine struct {
+	decoder config.ValueDecoder
+	factory SourceFactory
+}
+
+var (
+	sourceEnginesMu sync.RWMutex
+	sourceEngines   = make(map[string]sourceEngine)
+)
+
+// RegisterSource makes a source engine available under the given config type, decoder parses its "value" block
+func RegisterSource(sourceType string, decoder config.ValueDecoder, factory SourceFactory) {
+	sourceEnginesMu.Lock()
+	defer sourceEnginesMu.Unlock()
+
+	if decoder == nil || factory == nil {
+		panic("services: RegisterSource decoder or factory is nil")
+	}
+	if _, exists := sourceEngines[sourceType]; exists {
+		panic("services: RegisterSource called twice for source type " + sourceType)
+	}
+	sourceEngines[sourceType] = sourceEngine{decoder: decoder, factory: factory}
+}
+
+// SourceDecoder returns the configuration decoder registered for a source type, it is a config.DecoderLookup
+func SourceDecoder(sourceType string) (config.ValueDecoder, bool) {
+	sourceEnginesMu.RLock()
+	defer sourceEnginesMu.RUnlock()
+
+	engine, ok := sourceEngines[sourceType]
+	return engine.decoder, ok
+}
+
+// NewSource builds the source registered for source.Type
+func NewSource(source common.Source[any], workerConfig common.WorkerConfiguration) (Source, error) {
+	sourceEnginesMu.RLock()
+	engine, ok := sourceEngines[source.Type]
+	sourceEnginesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported source type %q, registered types: %v", source.Type, RegisteredSources())
+	}
+
+	return engine.factory(source, workerConfig)
+}
+
+// RegisteredSources returns the sorted list of registered source types
+func RegisteredSources() []string {
+	sourceEnginesMu.RLock()
+	defer sourceEnginesMu.RUnlock()
+
+	var types []string
+	for sourceType := range sourceEngines {
+		types = append(types, sourceType)
+	}
+	sort.Strings(types)
+	return types
+}
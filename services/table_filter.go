@@ -0,0 +1,140 @@
+package services
+
+import (
+	"fmt"
+	"migration-tool-go/dtos/sources/postgres"
+	"path"
+	"regexp"
+	"slices"
+)
+
+// tableRule matches the tables of the schemas matching its schema pattern
+type tableRule struct {
+	schema   string
+	patterns []string
+	regexes  []*regexp.Regexp
+}
+
+// matches reports whether the rule applies to the schema and returns the pattern matching the table, if any
+func (r tableRule) matches(schema string, table string) (applies bool, match string) {
+	if ok, _ := path.Match(r.schema, schema); !ok {
+		return false, ""
+	}
+
+	for _, pattern := range r.patterns {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true, pattern
+		}
+	}
+	for _, regex := range r.regexes {
+		if regex.MatchString(table) {
+			return true, regex.String()
+		}
+	}
+	return true, ""
+}
+
+// tableFilter selects the tables to migrate from the include and exclude lists of the configuration.
+// Schema names and table lists are glob patterns, regex lists are unanchored regular expressions.
+type tableFilter struct {
+	excludedSchemas []string
+	includes        []tableRule
+	excludes        []tableRule
+}
+
+// newTableFilter compiles the table selection of the configuration
+func newTableFilter(configuration postgres.Configuration) (*tableFilter, error) {
+	filter := &tableFilter{excludedSchemas: configuration.ExcludedSchemas}
+
+	for _, include := range configuration.IncludeTablesList {
+		filter.includes = append(filter.includes, tableRule{schema: include.Schema, patterns: include.Tables})
+	}
+	for _, include := range configuration.IncludeTableRegexList {
+		regexes, err := compileTableRegexes(include.Schema, include.Regex)
+		if err != nil {
+			return nil, err
+		}
+		filter.includes = append(filter.includes, tableRule{schema: include.Schema, regexes: regexes})
+	}
+
+	for _, exclude := range configuration.ExcludeTablesList {
+		filter.excludes = append(filter.excludes, tableRule{schema: exclude.Schema, patterns: exclude.Tables})
+	}
+	for _, exclude := range configuration.ExcludeTableRegexList {
+		regexes, err := compileTableRegexes(exclude.Schema, exclude.Regex)
+		if err != nil {
+			return nil, err
+		}
+		filter.excludes = append(filter.excludes, tableRule{schema: exclude.Schema, regexes: regexes})
+	}
+
+	// Surface malformed glob patterns at startup instead of silently never matching
+	for _, rule := range slices.Concat(filter.includes, filter.excludes) {
+		for _, pattern := range append([]string{rule.schema}, rule.patterns...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid table pattern %q for schema %s: %w", pattern, rule.schema, err)
+			}
+		}
+	}
+	for _, pattern := range filter.excludedSchemas {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid excluded schema pattern %q: %w", pattern, err)
+		}
+	}
+
+	return filter, nil
+}
+
+func compileTableRegexes(schema string, expressions []string) ([]*regexp.Regexp, error) {
+	var regexes []*regexp.Regexp
+	for _, expression := range expressions {
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid table regex %q for schema %s: %w", expression, schema, err)
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+// SchemaExcluded reports whether the schema matches excluded_schemas
+func (f *tableFilter) SchemaExcluded(schema string) bool {
+	for _, pattern := range f.excludedSchemas {
+		if ok, _ := path.Match(pattern, schema); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Select decides whether a table is migrated and returns the reason of the decision.
+// Excluded schemas are skipped first, then a table must match an include rule when its schema has any,
+// and finally exclude rules win over include rules.
+func (f *tableFilter) Select(schema string, table string) (bool, string) {
+	if f.SchemaExcluded(schema) {
+		return false, "schema is excluded"
+	}
+
+	hasIncludes, included := false, ""
+	for _, rule := range f.includes {
+		applies, match := rule.matches(schema, table)
+		hasIncludes = hasIncludes || applies
+		if included == "" {
+			included = match
+		}
+	}
+	if hasIncludes && included == "" {
+		return false, "not matched by any include rule of the schema"
+	}
+
+	for _, rule := range f.excludes {
+		if _, match := rule.matches(schema, table); match != "" {
+			return false, fmt.Sprintf("matched exclude rule %q", match)
+		}
+	}
+
+	if included != "" {
+		return true, fmt.Sprintf("matched include rule %q", included)
+	}
+	return true, "schema is selected"
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"migration-tool-go/dtos/sources/postgres"
+	"testing"
+)
+
+func TestTableFilterSelect(t *testing.T) {
+	filter, err := newTableFilter(postgres.Configuration{
+		ExcludedSchemas: []string{"pg_*", "audit"},
+		IncludeTablesList: []postgres.IncludeTablesList{
+			{Schema: "sales", Tables: []string{"orders", "order_*"}},
+			{Schema: "hr", Tables: []string{"employees"}},
+		},
+		IncludeTableRegexList: []postgres.IncludeTableRegexList{
+			{Schema: "hr", Regex: []string{"^payroll_\\d{4}$"}},
+		},
+		ExcludeTablesList: []postgres.ExcludeTablesList{
+			{Schema: "sales", Tables: []string{"order_tmp"}},
+			{Schema: "*", Tables: []string{"*_backup"}},
+		},
+		ExcludeTableRegexList: []postgres.ExcludeTableRegexList{
+			{Schema: "hr", Regex: []string{"2019"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newTableFilter: %v", err)
+	}
+
+	cases := []struct {
+		schema string
+		table  string
+		want   bool
+		reason string
+	}{
+		{"pg_catalog", "pg_class", false, "schema is excluded"},
+		{"audit", "events", false, "schema is excluded"},
+		{"sales", "orders", true, `matched include rule "orders"`},
+		{"sales", "order_items", true, `matched include rule "order_*"`},
+		{"sales", "customers", false, "not matched by any include rule of the schema"},
+		// Exclude rules win over include rules
+		{"sales", "order_tmp", false, `matched exclude rule "order_tmp"`},
+		{"sales", "order_backup", false, `matched exclude rule "*_backup"`},
+		// Glob lists and regex lists of a schema both include
+		{"hr", "employees", true, `matched include rule "employees"`},
+		{"hr", "payroll_2024", true, `matched include rule "^payroll_\\d{4}$"`},
+		{"hr", "payroll_2024_old", false, "not matched by any include rule of the schema"},
+		{"hr", "payroll_2019", false, `matched exclude rule "2019"`},
+		// Schemas without include rules are selected whole, exclude rules still apply
+		{"inventory", "items", true, "schema is selected"},
+		{"inventory", "items_backup", false, `matched exclude rule "*_backup"`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.schema+"."+tc.table, func(t *testing.T) {
+			selected, reason := filter.Select(tc.schema, tc.table)
+			if selected != tc.want || reason != tc.reason {
+				t.Errorf("Select(%s, %s) = %v, %q, want %v, %q", tc.schema, tc.table, selected, reason, tc.want, tc.reason)
+			}
+		})
+	}
+}
+
+func TestNewTableFilterRejectsInvalidPatterns(t *testing.T) {
+	cases := []struct {
+		name          string
+		configuration postgres.Configuration
+	}{
+		{"table glob", postgres.Configuration{IncludeTablesList: []postgres.IncludeTablesList{{Schema: "sales", Tables: []string{"order_["}}}}},
+		{"schema glob", postgres.Configuration{ExcludeTablesList: []postgres.ExcludeTablesList{{Schema: "[", Tables: []string{"orders"}}}}},
+		{"excluded schema", postgres.Configuration{ExcludedSchemas: []string{"pg_["}}},
+		{"regex", postgres.Configuration{IncludeTableRegexList: []postgres.IncludeTableRegexList{{Schema: "sales", Regex: []string{"(orders"}}}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newTableFilter(tc.configuration); err == nil {
+				t.Errorf("newTableFilter accepted an invalid %s", tc.name)
+			}
+		})
+	}
+}
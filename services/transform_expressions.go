@@ -0,0 +1,234 @@
+package services
+
+import (
+	"fmt"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/common"
+	"migration-tool-go/dtos/destinations/doris"
+	"migration-tool-go/repository"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/builtin"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+	"github.com/samber/lo"
+)
+
+// dropWhenAudit is the audit entry counting the records dropped by the drop_when expression of a table
+const dropWhenAudit = ""
+
+// tableExpressions are the expressions of a table, compiled against its columns
+type tableExpressions struct {
+	derived  []derivedProgram
+	dropWhen *vm.Program
+}
+
+type derivedProgram struct {
+	column  dtos.ColumnInfo
+	program *vm.Program
+}
+
+// expressionFunctions are the functions expressions can call besides the builtins of expr
+var expressionFunctions = map[string]func(params ...any) (any, error){
+	"date_trunc": dateTrunc,
+}
+
+// checkExpressions parses the expressions of every table, so syntax errors are reported when the tool starts.
+// The names they use are checked by compileExpressions once the columns of the table are discovered.
+func checkExpressions(config common.TransformConfiguration) error {
+	for _, transforms := range config.Tables {
+		for _, derived := range transforms.Derived {
+			if _, err := parser.Parse(derived.Expression); err != nil {
+				return fmt.Errorf("invalid expression of derived column %s.%s.%s: %w", transforms.Schema, transforms.Table, derived.Column, err)
+			}
+		}
+		if transforms.DropWhen != "" {
+			if _, err := parser.Parse(transforms.DropWhen); err != nil {
+				return fmt.Errorf("invalid drop_when expression of %s.%s: %w", transforms.Schema, transforms.Table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compileExpressions compiles the expressions of a table against its columns, an expression can only use the
+// extracted columns and the derived columns defined before it
+func compileExpressions(table dtos.TableInfo, transforms common.TableTransforms) (*tableExpressions, error) {
+	columns := lo.SliceToMap(table.Columns, func(column dtos.ColumnInfo) (string, bool) { return column.Name, true })
+
+	compiled := &tableExpressions{}
+	for _, derived := range transforms.Derived {
+		if columns[derived.Column] {
+			return nil, fmt.Errorf("derived column %s is already a column of %s", derived.Column, tableKey(table))
+		}
+		program, err := compileExpression(derived.Expression, columns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression of derived column %s of %s: %w", derived.Column, tableKey(table), err)
+		}
+		compiled.derived = append(compiled.derived, derivedProgram{column: derivedColumn(table, derived), program: program})
+		columns[derived.Column] = true
+	}
+
+	if transforms.DropWhen != "" {
+		program, err := compileExpression(transforms.DropWhen, columns, expr.AsBool())
+		if err != nil {
+			return nil, fmt.Errorf("invalid drop_when expression of %s: %w", tableKey(table), err)
+		}
+		compiled.dropWhen = program
+	}
+
+	return compiled, nil
+}
+
+// compileExpression compiles an expression over the columns of a record. Column values are only typed at run
+// time, so the expression is compiled without an environment and the names it reads are checked against the columns.
+func compileExpression(code string, columns map[string]bool, options ...expr.Option) (*vm.Program, error) {
+	for name, function := range expressionFunctions {
+		options = append(options, expr.Function(name, function))
+	}
+	// A column named like a builtin, such as first or date, is read as the column
+	for _, name := range builtin.Names {
+		if columns[name] {
+			options = append(options, expr.DisableBuiltin(name))
+		}
+	}
+
+	program, err := expr.Compile(code, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]bool)
+	var names []string
+	ast.Find(program.Node(), func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.VariableDeclaratorNode:
+			declared[n.Name] = true
+		case *ast.IdentifierNode:
+			names = append(names, n.Value)
+		}
+		return false
+	})
+	for _, name := range names {
+		if !columns[name] && !declared[name] && !lo.HasKey(expressionFunctions, name) {
+			return nil, fmt.Errorf("unknown column or function %s", name)
+		}
+	}
+	return program, nil
+}
+
+// derivedColumn describes a derived column like an extracted one, derived values can always be NULL
+func derivedColumn(table dtos.TableInfo, derived common.DerivedColumn) dtos.ColumnInfo {
+	return dtos.ColumnInfo{
+		Schema:     table.TableSchema,
+		Table:      table.TableName,
+		Name:       derived.Column,
+		DataType:   derived.Type,
+		IsNullable: true,
+	}
+}
+
+// evaluate computes the derived columns of the records and returns the records drop_when keeps.
+// Deletes of change data capture only carry the key and are always kept, inserts and updates are evaluated.
+func (e *tableExpressions) evaluate(records []map[string]any) ([]map[string]any, error) {
+	kept := records[:0:0]
+	for _, record := range records {
+		if record[doris.DeleteSignColumn] == 1 {
+			kept = append(kept, record)
+			continue
+		}
+
+		for _, derived := range e.derived {
+			value, err := expr.Run(derived.program, record)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute derived column %s: %w", derived.column.Name, err)
+			}
+			// Derived values are loaded in the text form of their type, like the extracted values
+			if text, ok := normalizeSourceValue(derived.column.DataType, value); ok {
+				record[derived.column.Name] = text
+			} else {
+				record[derived.column.Name] = nil
+			}
+		}
+
+		if e.dropWhen != nil {
+			value, err := expr.Run(e.dropWhen, record)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate drop_when: %w", err)
+			}
+			drop, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("drop_when evaluated to %T instead of a bool", value)
+			}
+			if drop {
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
+	return kept, nil
+}
+
+// dateTrunc truncates a timestamp to the start of a year, quarter, month, week, day, hour, minute or second
+// like the date_trunc function of PostgreSQL. NULL stays NULL.
+func dateTrunc(params ...any) (any, error) {
+	if len(params) != 2 {
+		return nil, fmt.Errorf("date_trunc expects a unit and a timestamp, got %d arguments", len(params))
+	}
+	unit, ok := params[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("date_trunc unit must be a string, got %T", params[0])
+	}
+
+	var value time.Time
+	switch v := params[1].(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		value = v
+	case string:
+		parsed, err := parseTimestamp(v)
+		if err != nil {
+			return nil, err
+		}
+		value = parsed
+	default:
+		return nil, fmt.Errorf("date_trunc expects a timestamp, got %T", params[1])
+	}
+
+	year, month, day := value.Date()
+	location := value.Location()
+	switch unit {
+	case "year":
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, location), nil
+	case "quarter":
+		return time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, location), nil
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, location), nil
+	case "week":
+		// Weeks start on Monday
+		offset := (int(value.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, location), nil
+	case "day":
+		return time.Date(year, month, day, 0, 0, 0, 0, location), nil
+	case "hour":
+		return time.Date(year, month, day, value.Hour(), 0, 0, 0, location), nil
+	case "minute":
+		return time.Date(year, month, day, value.Hour(), value.Minute(), 0, 0, location), nil
+	case "second":
+		return time.Date(year, month, day, value.Hour(), value.Minute(), value.Second(), 0, location), nil
+	}
+	return nil, fmt.Errorf("unknown date_trunc unit %q", unit)
+}
+
+// parseTimestamp parses the text of an extracted date, timestamp or timestamptz value
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range []string{normalizedDatetimeLayout, repository.TimestamptzKeyLayout, time.DateOnly, time.RFC3339Nano} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date_trunc cannot parse %q as a timestamp", value)
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/common"
+	"migration-tool-go/logger"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/samber/lo"
+)
+
+// defaultMask replaces the values of a mask rule without a mask
+const defaultMask = "****"
+
+// transformService applies the configured column transforms and expressions to the extracted records and audits them
+type transformService struct {
+	mu        sync.Mutex
+	config    common.TransformConfiguration
+	salt      []byte
+	startTime time.Time
+	// expressions are compiled when the tables are discovered, keyed by schema.table
+	expressions map[string]*tableExpressions
+	// audit counts the transformed records per table and column, and the records dropped per table
+	audit map[string]map[string]uint64
+}
+
+// TransformService is the global transform service instance
+var TransformService *transformService
+
+// NewTransformService validates the transform rules and the syntax of the expressions
+func NewTransformService(config common.TransformConfiguration) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if err := checkExpressions(config); err != nil {
+		return err
+	}
+
+	TransformService = &transformService{
+		config:      config,
+		salt:        []byte(config.GetSalt()),
+		startTime:   time.Now(),
+		expressions: make(map[string]*tableExpressions),
+		audit:       make(map[string]map[string]uint64),
+	}
+
+	logger.Sugar.Infof("Transform service initialized with %d tables and audit file=%s", len(config.Tables), config.GetAuditFile())
+	return nil
+}
+
+// Table removes the dropped columns from the table and compiles its expressions against the remaining ones,
+// so an expression using an unknown name fails the discovery before any load starts.
+// Transforms of unknown or key columns are rejected.
+func (t *transformService) Table(table dtos.TableInfo) (dtos.TableInfo, error) {
+	tableTransforms, ok := t.config.GetTableTransforms(table.TableSchema, table.TableName)
+	if !ok {
+		return table, nil
+	}
+
+	for _, transform := range tableTransforms.Columns {
+		if !lo.ContainsBy(table.Columns, func(column dtos.ColumnInfo) bool { return column.Name == transform.Column }) {
+			return table, fmt.Errorf("transformed column %s does not exist in %s.%s", transform.Column, table.TableSchema, table.TableName)
+		}
+		if lo.ContainsBy(table.PrimaryKeys, func(key dtos.PrimaryKey) bool { return key.ColumnName == transform.Column }) {
+			return table, fmt.Errorf("column %s of %s.%s is a key column and cannot be transformed", transform.Column, table.TableSchema, table.TableName)
+		}
+	}
+
+	table.Columns = lo.Filter(table.Columns, func(column dtos.ColumnInfo, _ int) bool {
+		return t.rule(table, column.Name) != common.TransformDrop
+	})
+
+	compiled, err := compileExpressions(table, tableTransforms)
+	if err != nil {
+		return table, err
+	}
+	t.mu.Lock()
+	t.expressions[tableKey(table)] = compiled
+	t.mu.Unlock()
+	return table, nil
+}
+
+// DerivedColumns returns the columns computed by the expressions of a table, loaded after its extracted columns
+func (t *transformService) DerivedColumns(table dtos.TableInfo) []dtos.ColumnInfo {
+	tableTransforms, _ := t.config.GetTableTransforms(table.TableSchema, table.TableName)
+	return lo.Map(tableTransforms.Derived, func(derived common.DerivedColumn, _ int) dtos.ColumnInfo {
+		return derivedColumn(table, derived)
+	})
+}
+
+// DropsRows reports whether the table has a drop_when expression
+func (t *transformService) DropsRows(table dtos.TableInfo) bool {
+	tableTransforms, _ := t.config.GetTableTransforms(table.TableSchema, table.TableName)
+	return tableTransforms.DropWhen != ""
+}
+
+// tableExpressions returns the expressions of a table compiled by Table
+func (t *transformService) tableExpressions(table dtos.TableInfo) (*tableExpressions, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	compiled, ok := t.expressions[tableKey(table)]
+	if !ok {
+		return nil, fmt.Errorf("expressions of %s were not compiled when the table was discovered", tableKey(table))
+	}
+	return compiled, nil
+}
+
+// ColumnType returns the type a column is loaded as, rewritten values are always text
+func (t *transformService) ColumnType(table dtos.TableInfo, column dtos.ColumnInfo) (dtos.ColumnInfo, bool) {
+	switch t.rule(table, column.Name) {
+	case "", common.TransformDrop:
+		return column, false
+	case common.TransformNull:
+		column.IsNullable = true
+	default:
+		column.DataType = "text"
+		column.MaxLength.Valid = false
+	}
+	return column, true
+}
+
+// Apply transforms the records of a table in place, computes their derived columns and returns the records
+// drop_when keeps, counting them in the audit. The column transforms are applied before the expressions,
+// so the expressions never see the values they replace.
+func (t *transformService) Apply(table dtos.TableInfo, records []map[string]any) ([]map[string]any, error) {
+	return t.apply(table, records, true)
+}
+
+// apply transforms the records, counting them in the audit when audit is set
+func (t *transformService) apply(table dtos.TableInfo, records []map[string]any, audit bool) ([]map[string]any, error) {
+	tableTransforms, ok := t.config.GetTableTransforms(table.TableSchema, table.TableName)
+	if !ok || len(records) == 0 {
+		return records, nil
+	}
+
+	t.transform(table, records)
+
+	compiled, err := t.tableExpressions(table)
+	if err != nil {
+		return nil, err
+	}
+	kept, err := compiled.evaluate(records)
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", tableKey(table), err)
+	}
+	if !audit {
+		return kept, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts, ok := t.audit[tableKey(table)]
+	if !ok {
+		counts = make(map[string]uint64)
+		t.audit[tableKey(table)] = counts
+	}
+	for _, transform := range tableTransforms.Columns {
+		counts[transform.Column] += uint64(len(records))
+	}
+	for _, derived := range tableTransforms.Derived {
+		counts[derived.Column] += uint64(len(records))
+	}
+	counts[dropWhenAudit] += uint64(len(records) - len(kept))
+	return kept, nil
+}
+
+// transform applies the column transforms to the records
+func (t *transformService) transform(table dtos.TableInfo, records []map[string]any) {
+	transforms := t.config.GetTransforms(table.TableSchema, table.TableName)
+	columns := lo.SliceToMap(table.Columns, func(column dtos.ColumnInfo) (string, dtos.ColumnInfo) {
+		return column.Name, column
+	})
+
+	for _, record := range records {
+		for _, transform := range transforms {
+			value, ok := record[transform.Column]
+			if !ok {
+				continue
+			}
+			if transform.Rule == common.TransformDrop {
+				delete(record, transform.Column)
+				continue
+			}
+			if transform.Rule == common.TransformNull {
+				record[transform.Column] = nil
+				continue
+			}
+			// Values are transformed in the text form they are loaded in
+			text, ok := normalizeSourceValue(columns[transform.Column].DataType, value)
+			if !ok {
+				record[transform.Column] = nil
+				continue
+			}
+			record[transform.Column] = t.transformValue(transform, text)
+		}
+	}
+}
+
+// transformValue applies a value rewriting rule to the text of a value
+func (t *transformService) transformValue(transform common.ColumnTransform, value string) string {
+	switch transform.Rule {
+	case common.TransformMask:
+		if transform.Mask == "" {
+			return defaultMask
+		}
+		return transform.Mask
+	case common.TransformKeepLast:
+		return keepLast(value, transform.KeepLast)
+	case common.TransformHash:
+		sum := sha256.Sum256(append(append([]byte{}, t.salt...), value...))
+		return hex.EncodeToString(sum[:])
+	case common.TransformTokenize:
+		return t.tokenize(value)
+	case common.TransformFake:
+		return t.fake(transform.Fake, value)
+	}
+	return value
+}
+
+// keepLast masks every character but the last n, values of at most n characters are masked entirely
+func keepLast(value string, n int) string {
+	runes := []rune(value)
+	if len(runes) <= n {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-n) + string(runes[len(runes)-n:])
+}
+
+// tokenize replaces every digit with a digit and every letter with a letter of the same case, keeping the
+// other characters so the value keeps its format. Equal values get equal tokens.
+func (t *transformService) tokenize(value string) string {
+	stream := t.keyStream(common.TransformTokenize, value)
+
+	var token strings.Builder
+	for idx, r := range []rune(value) {
+		shift := rune(stream(idx))
+		switch {
+		case r >= '0' && r <= '9':
+			token.WriteRune('0' + (r-'0'+shift)%10)
+		case r >= 'a' && r <= 'z':
+			token.WriteRune('a' + (r-'a'+shift)%26)
+		case r >= 'A' && r <= 'Z':
+			token.WriteRune('A' + (r-'A'+shift)%26)
+		case unicode.IsLetter(r):
+			token.WriteRune('a' + shift%26)
+		default:
+			token.WriteRune(r)
+		}
+	}
+	return token.String()
+}
+
+// keyStream returns the bytes of the salted HMAC-SHA256 stream of a value, extended with a counter
+func (t *transformService) keyStream(purpose string, value string) func(idx int) byte {
+	var blocks [][]byte
+	return func(idx int) byte {
+		for len(blocks) <= idx/sha256.Size {
+			mac := hmac.New(sha256.New, t.salt)
+			counter := make([]byte, 4)
+			binary.BigEndian.PutUint32(counter, uint32(len(blocks)))
+			mac.Write(counter)
+			mac.Write([]byte(purpose))
+			mac.Write([]byte{0})
+			mac.Write([]byte(value))
+			blocks = append(blocks, mac.Sum(nil))
+		}
+		return blocks[idx/sha256.Size][idx%sha256.Size]
+	}
+}
+
+// fake derives a fake value of the kind from the salted value, equal values get equal fakes
+func (t *transformService) fake(kind string, value string) string {
+	mac := hmac.New(sha256.New, t.salt)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	sum := mac.Sum(nil)
+	seed := binary.BigEndian.Uint64(sum[:8])
+	first := fakeFirstNames[seed%uint64(len(fakeFirstNames))]
+	last := fakeLastNames[binary.BigEndian.Uint64(sum[8:16])%uint64(len(fakeLastNames))]
+	number := binary.BigEndian.Uint64(sum[16:24])
+
+	switch kind {
+	case common.FakeFirstName:
+		return first
+	case common.FakeLastName:
+		return last
+	case common.FakeEmail:
+		return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), number%10000)
+	case common.FakePhone:
+		// 555-01XX numbers are reserved for fiction
+		return fmt.Sprintf("+1-%03d-555-01%02d", 200+number%800, (number/800)%100)
+	}
+	return first + " " + last
+}
+
+// rule returns the transform rule of a column, empty when the column is not transformed
+func (t *transformService) rule(table dtos.TableInfo, column string) string {
+	for _, transform := range t.config.GetTransforms(table.TableSchema, table.TableName) {
+		if transform.Column == column {
+			return transform.Rule
+		}
+	}
+	return ""
+}
+
+// WriteAudit logs the transformed columns of the run and writes them to the audit file
+func (t *transformService) WriteAudit(command string) error {
+	if len(t.config.Tables) == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	audit := dtos.TransformAudit{
+		Command:     command,
+		StartedAt:   t.startTime,
+		CompletedAt: time.Now(),
+		Columns:     []dtos.TransformedColumn{},
+	}
+	for _, tables := range t.config.Tables {
+		counts := t.audit[checkpointKey(tables.Schema, tables.Table)]
+		for _, transform := range tables.Columns {
+			audit.Columns = append(audit.Columns, dtos.TransformedColumn{
+				Schema:  tables.Schema,
+				Table:   tables.Table,
+				Column:  transform.Column,
+				Rule:    transform.Rule,
+				Records: counts[transform.Column],
+			})
+		}
+		for _, derived := range tables.Derived {
+			audit.Columns = append(audit.Columns, dtos.TransformedColumn{
+				Schema:  tables.Schema,
+				Table:   tables.Table,
+				Column:  derived.Column,
+				Rule:    "derived",
+				Records: counts[derived.Column],
+			})
+		}
+		if tables.DropWhen != "" {
+			audit.Columns = append(audit.Columns, dtos.TransformedColumn{
+				Schema:  tables.Schema,
+				Table:   tables.Table,
+				Rule:    "drop_when",
+				Records: counts[dropWhenAudit],
+			})
+		}
+	}
+	t.mu.Unlock()
+
+	sort.SliceStable(audit.Columns, func(i, j int) bool {
+		a, b := audit.Columns[i], audit.Columns[j]
+		return a.Schema+"."+a.Table < b.Schema+"."+b.Table
+	})
+	for _, column := range audit.Columns {
+		if column.Column == "" {
+			logger.Sugar.Infof("Dropped %d records of %s.%s with drop_when", column.Records, column.Schema, column.Table)
+			continue
+		}
+		logger.Sugar.Infof("Transformed column %s of %s.%s with rule %s in %d records", column.Column, column.Schema, column.Table, column.Rule, column.Records)
+	}
+
+	data, err := json.MarshalIndent(audit, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transform audit: %w", err)
+	}
+
+	if err := os.WriteFile(t.config.GetAuditFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write transform audit: %w", err)
+	}
+
+	logger.Sugar.Infof("Transform audit written to %s", t.config.GetAuditFile())
+	return nil
+}
+
+// Names fake values are built from
+var (
+	fakeFirstNames = []string{
+		"Alex", "Avery", "Blake", "Casey", "Charlie", "Dakota", "Drew", "Elliot", "Emerson", "Finley",
+		"Harper", "Hayden", "Jamie", "Jordan", "Kai", "Kendall", "Logan", "Morgan", "Parker", "Quinn",
+		"Reese", "Riley", "Rowan", "Sage", "Sawyer", "Skyler", "Taylor", "Peyton", "Robin", "Cameron",
+	}
+	fakeLastNames = []string{
+		"Anderson", "Bennett", "Carter", "Dawson", "Ellis", "Fisher", "Garcia", "Hughes", "Irwin", "Jensen",
+		"Keller", "Lopez", "Mitchell", "Nguyen", "Owens", "Patel", "Quinn", "Reyes", "Schmidt", "Turner",
+		"Underwood", "Vargas", "Walker", "Xu", "Young", "Zimmerman", "Brooks", "Coleman", "Foster", "Hayes",
+	}
+)
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"migration-tool-go/config"
+	"migration-tool-go/dtos"
+	"migration-tool-go/dtos/common"
+	"migration-tool-go/logger"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+// validationService compares the source tables with their Doris copies, by row count and by key range checksums
+type validationService struct {
+	source       *postgresMigration
+	sink         *dorisSyncService
+	config       common.ValidationConfiguration
+	workerConfig common.WorkerConfiguration
+	db           *sql.DB
+}
+
+// rangeChecksum is the order independent checksum of the rows of a key range
+type rangeChecksum struct {
+	rows int
+	sum  uint64
+}
+
+// NewValidationService creates the validation service and connects to the MySQL protocol port of the Doris FE
+func NewValidationService(ctx context.Context, source Source, sink Sink, validationConfig common.ValidationConfiguration, workerConfig common.WorkerConfiguration) (*validationService, error) {
+	postgresSource, ok := source.(*postgresMigration)
+	if !ok {
+		return nil, fmt.Errorf("validation requires a postgres source, got %T", source)
+	}
+	dorisSink, ok := sink.(*dorisSyncService)
+	if !ok {
+		return nil, fmt.Errorf("validation requires a doris destination, got %T", sink)
+	}
+
+	db, err := config.NewDorisQueryConnection(ctx, dorisSink.connectionDetails)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(max(workerConfig.NoOfWorkers, 1))
+
+	logger.Sugar.Infof("Validation service initialized with count only=%v, report file=%s", validationConfig.CountOnly, validationConfig.GetReportFile())
+
+	return &validationService{
+		source:       postgresSource,
+		sink:         dorisSink,
+		config:       validationConfig,
+		workerConfig: workerConfig,
+		db:           db,
+	}, nil
+}
+
+// Close closes the Doris connection
+func (v *validationService) Close() error {
+	return v.db.Close()
+}
+
+// ValidateTables validates every selected table
+func (v *validationService) ValidateTables(ctx context.Context) ([]dtos.TableValidation, error) {
+	tables, err := v.source.discoverTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []dtos.TableValidation
+	for _, table := range tables {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		results = append(results, v.ValidateTable(ctx, table))
+	}
+
+	return results, nil
+}
+
+// ValidateTable compares the row counts of a table and, unless count_only is set, the checksums of each
+// of its key ranges. Incremental windows are ignored, every row matching the row filter of the table is compared.
+func (v *validationService) ValidateTable(ctx context.Context, table dtos.TableInfo) dtos.TableValidation {
+	result := dtos.TableValidation{
+		Schema:      table.TableSchema,
+		Table:       table.TableName,
+		TargetTable: v.sink.target(table).String(),
+		KeyStrategy: table.KeyStrategy,
+	}
+	// Incremental windows only bound a single run, the row filter bounds what the destination holds
+	table.Predicates = nil
+	if predicate, ok := v.source.configuration.GetRowFilter(table.TableSchema, table.TableName); ok {
+		table.Predicates = []dtos.Predicate{predicate}
+	}
+
+	fail := func(err error) dtos.TableValidation {
+		result.Error = err.Error()
+		logger.Sugar.Errorf("Validation of %s.%s failed: %v", table.TableSchema, table.TableName, err)
+		return result
+	}
+
+	sourceCount, err := v.source.repo.CountRows(ctx, table.TableSchema, table.TableName, table.Predicates)
+	if err != nil {
+		return fail(fmt.Errorf("failed to count source rows: %w", err))
+	}
+	result.SourceCount = sourceCount
+
+	err = v.db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s", v.targetTable(table))).Scan(&result.DestinationCount)
+	if err != nil {
+		return fail(fmt.Errorf("failed to count destination rows: %w", err))
+	}
+
+	if TransformService.DropsRows(table) && (v.config.CountOnly || table.KeyStrategy == dtos.KeyStrategyCtid) {
+		logger.Sugar.Warnf("Table %s.%s drops rows with drop_when, its source count includes the dropped rows", table.TableSchema, table.TableName)
+	}
+	if !v.config.CountOnly {
+		if table.KeyStrategy == dtos.KeyStrategyCtid {
+			logger.Sugar.Warnf("Table %s.%s has no key to compare ranges by, only its row count is validated", table.TableSchema, table.TableName)
+		} else if err := v.compareKeyRanges(ctx, table, &result); err != nil {
+			return fail(err)
+		}
+	}
+
+	result.Passed = result.SourceCount == result.DestinationCount && len(result.MismatchedRanges) == 0
+	if result.Passed {
+		logger.Sugar.Infof("✅ Validation of %s.%s passed: %d rows, %d key ranges checked", table.TableSchema, table.TableName, result.SourceCount, result.RangesChecked)
+	} else {
+		logger.Sugar.Warnf("❌ Validation of %s.%s failed: source rows %d, destination rows %d, %d of %d key ranges mismatched",
+			table.TableSchema, table.TableName, result.SourceCount, result.DestinationCount, len(result.MismatchedRanges), result.RangesChecked)
+	}
+
+	return result
+}
+
+// compareKeyRanges splits the table with the same key ranges the migration uses and compares the checksum
+// of every range on both sides, so differences are narrowed down to the ranges holding them
+func (v *validationService) compareKeyRanges(ctx context.Context, table dtos.TableInfo, result *dtos.TableValidation) error {
+	columns, err := v.checksumColumns(table)
+	if err != nil {
+		return err
+	}
+	result.ChecksumColumns = lo.Map(columns, func(column dtos.ColumnInfo, _ int) string { return column.Name })
+
+	infoChan := dtos.NewTableInfoChan(table, v.workerConfig.WorkerBatchSize, v.workerConfig.IdBatchSize)
+
+	var planErr error
+	go func() {
+		planErr = v.source.PlanKeyRanges(ctx, infoChan)
+		close(infoChan.PrimaryKeyRange)
+	}()
+
+	var mu sync.Mutex
+	var rangeErr error
+	var keptRows int64
+	wg := sync.WaitGroup{}
+	parallelProcessingChan := make(chan bool, max(v.workerConfig.NoOfWorkers, 1))
+
+	for keyRange := range infoChan.PrimaryKeyRange {
+		parallelProcessingChan <- true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-parallelProcessingChan }()
+
+			mismatch, sourceRows, err := v.compareKeyRange(ctx, table, columns, keyRange)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.RangesChecked++
+			keptRows += int64(sourceRows)
+			if err != nil && rangeErr == nil {
+				rangeErr = err
+			}
+			if mismatch != nil {
+				result.MismatchedRanges = append(result.MismatchedRanges, *mismatch)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if planErr != nil {
+		return fmt.Errorf("failed to plan key ranges: %w", planErr)
+	}
+	// The rows dropped by drop_when are never loaded, only the key ranges can count the rows kept
+	if rangeErr == nil && TransformService.DropsRows(table) {
+		result.SourceCount = keptRows
+	}
+	return rangeErr
+}
+
+// compareKeyRange returns the mismatch of a key range, nil when both sides hold the same rows, and the number of
+// source rows the range holds
+func (v *validationService) compareKeyRange(ctx context.Context, table dtos.TableInfo, columns []dtos.ColumnInfo, keyRange dtos.PrimaryKeyRange) (*dtos.RangeMismatch, int, error) {
+	var sourceRecords []map[string]any
+	var start, end map[string]any
+	var err error
+
+	switch keyRange.Type {
+	case "id_range":
+		key := table.PrimaryKeys[0].ColumnName
+		start = map[string]any{key: keyRange.IdRange[0]}
+		end = map[string]any{key: keyRange.IdRange[1]}
+		sourceRecords, err = v.source.repo.GetRecordsById(ctx, table.Columns, key, table.TableSchema, table.TableName, keyRange.IdRange[0], keyRange.IdRange[1], table.Predicates)
+	case "multi_key":
+		start, end = keyRange.MultiKeyRange[0], keyRange.MultiKeyRange[1]
+		sourceRecords, err = v.source.repo.GetRecordsByMultiPrimaryKeys(ctx, table.Columns, table.PrimaryKeys, table.TableSchema, table.TableName, start, end, table.Predicates)
+	default:
+		return nil, 0, fmt.Errorf("unsupported key range type %s", keyRange.Type)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read source key range: %w", err)
+	}
+	// The destination holds the transformed values without the dropped rows, the transforms are deterministic
+	sourceRecords, err = TransformService.apply(table, sourceRecords, false)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sourceChecksum := rangeChecksum{}
+	for _, record := range sourceRecords {
+		sourceChecksum.add(lo.Map(columns, func(column dtos.ColumnInfo, _ int) *string {
+			if value, ok := normalizeSourceValue(column.DataType, record[column.Name]); ok {
+				return &value
+			}
+			return nil
+		}))
+	}
+
+	destinationChecksum, err := v.destinationChecksum(ctx, table, columns, start, end)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read destination key range: %w", err)
+	}
+
+	if sourceChecksum == destinationChecksum {
+		return nil, sourceChecksum.rows, nil
+	}
+
+	return &dtos.RangeMismatch{
+		Start:               normalizeKey(table, start),
+		End:                 normalizeKey(table, end),
+		SourceRows:          sourceChecksum.rows,
+		DestinationRows:     destinationChecksum.rows,
+		SourceChecksum:      fmt.Sprintf("%016x", sourceChecksum.sum),
+		DestinationChecksum: fmt.Sprintf("%016x", destinationChecksum.sum),
+	}, sourceChecksum.rows, nil
+}
+
+// destinationChecksum reads the rows of a key range from Doris
+func (v *validationService) destinationChecksum(ctx context.Context, table dtos.TableInfo, columns []dtos.ColumnInfo, start map[string]any, end map[string]any) (rangeChecksum, error) {
+	// Renamed columns are queried by their Doris name
+	columnName := func(column string) string { return v.sink.columnName(table, column) }
+	dorisKey := func(_ any, column string) string { return columnName(column) }
+	keys := lo.Map(table.PrimaryKeys, func(key dtos.PrimaryKey, _ int) string { return columnName(key.ColumnName) })
+
+	lowerClause, lowerArgs := keyBound(keys, lo.MapKeys(keyArgs(table, start), dorisKey), ">")
+	upperClause, upperArgs := keyBound(keys, lo.MapKeys(keyArgs(table, end), dorisKey), "<")
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE (%s) AND (%s)",
+		strings.Join(lo.Map(columns, func(column dtos.ColumnInfo, _ int) string { return quoteDorisIdentifier(columnName(column.Name)) }), ", "),
+		v.targetTable(table), lowerClause, upperClause)
+
+	rows, err := v.db.QueryContext(ctx, query, append(lowerArgs, upperArgs...)...)
+	if err != nil {
+		return rangeChecksum{}, err
+	}
+	defer rows.Close()
+
+	checksum := rangeChecksum{}
+	values := make([]sql.NullString, len(columns))
+	pointers := lo.Map(values, func(_ sql.NullString, i int) any { return &values[i] })
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return rangeChecksum{}, err
+		}
+		checksum.add(lo.Map(columns, func(column dtos.ColumnInfo, i int) *string {
+			if !values[i].Valid {
+				return nil
+			}
+			value := normalizeDestinationValue(column.DataType, values[i].String)
+			return &value
+		}))
+	}
+
+	return checksum, rows.Err()
+}
+
+// checksumColumns returns the columns of the table compared per key range, as they are loaded
+func (v *validationService) checksumColumns(table dtos.TableInfo) ([]dtos.ColumnInfo, error) {
+	loaded := func(column dtos.ColumnInfo, _ int) dtos.ColumnInfo {
+		column, _ = TransformService.ColumnType(table, column)
+		return column
+	}
+
+	names, ok := v.config.GetColumns(table.TableSchema, table.TableName)
+	if !ok {
+		return lo.Map(table.Columns, loaded), nil
+	}
+
+	columns := lo.SliceToMap(table.Columns, func(column dtos.ColumnInfo) (string, dtos.ColumnInfo) {
+		return column.Name, column
+	})
+
+	var selected []dtos.ColumnInfo
+	for _, name := range names {
+		column, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("validation column %s does not exist in %s.%s", name, table.TableSchema, table.TableName)
+		}
+		selected = append(selected, column)
+	}
+	return lo.Map(selected, loaded), nil
+}
+
+func (v *validationService) targetTable(table dtos.TableInfo) string {
+	target := v.sink.target(table)
+	return quoteDorisIdentifier(target.Database) + "." + quoteDorisIdentifier(target.Table)
+}
+
+// WriteReport writes the validation results to the report file
+func (v *validationService) WriteReport(results []dtos.TableValidation) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal validation report: %w", err)
+	}
+
+	if err := os.WriteFile(v.config.GetReportFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write validation report: %w", err)
+	}
+
+	logger.Sugar.Infof("Validation report written to %s", v.config.GetReportFile())
+	return nil
+}
+
+// add folds a row into the checksum, nil values are NULLs
+func (c *rangeChecksum) add(values []*string) {
+	hash := fnv.New64a()
+	length := make([]byte, binary.MaxVarintLen64)
+	for _, value := range values {
+		// Length prefixes keep ("ab", "c") and ("a", "bc") apart, -1 marks NULL
+		if value == nil {
+			hash.Write(length[:binary.PutVarint(length, -1)])
+			continue
+		}
+		hash.Write(length[:binary.PutVarint(length, int64(len(*value)))])
+		hash.Write([]byte(*value))
+	}
+
+	c.rows++
+	c.sum += hash.Sum64()
+}
+
+// normalizeKey converts the key values of a range bound to their canonical text
+func normalizeKey(table dtos.TableInfo, key map[string]any) map[string]any {
+	normalized := make(map[string]any, len(key))
+	for _, primaryKey := range table.PrimaryKeys {
+		if value, ok := normalizeSourceValue(primaryKey.DataType, key[primaryKey.ColumnName]); ok {
+			normalized[primaryKey.ColumnName] = value
+		} else {
+			normalized[primaryKey.ColumnName] = nil
+		}
+	}
+	return normalized
+}
+
+// keyArgs converts the key values of a range bound to query arguments, numbers stay numbers so that Doris
+// compares them numerically while every other type is bound as its canonical text
+func keyArgs(table dtos.TableInfo, key map[string]any) map[string]any {
+	args := normalizeKey(table, key)
+	for _, primaryKey := range table.PrimaryKeys {
+		value := key[primaryKey.ColumnName]
+		if pointer, ok := value.(*any); ok && pointer != nil {
+			value = *pointer
+		}
+		switch value.(type) {
+		case int16, int32, int64, float32, float64:
+			args[primaryKey.ColumnName] = value
+		}
+	}
+	return args
+}
+
+// keyBound builds the inclusive lexicographic comparison of the key columns with a bound, op is > or <.
+// Doris has no row value comparison, (a, b) >= (x, y) is expanded to a > x OR (a = x AND b >= y).
+func keyBound(keys []string, bound map[string]any, op string) (string, []any) {
+	var clauses []string
+	var args []any
+
+	for i := range keys {
+		var terms []string
+		for _, key := range keys[:i] {
+			terms = append(terms, fmt.Sprintf("%s = ?", quoteDorisIdentifier(key)))
+			args = append(args, bound[key])
+		}
+
+		comparison := op
+		if i == len(keys)-1 {
+			comparison += "="
+		}
+		terms = append(terms, fmt.Sprintf("%s %s ?", quoteDorisIdentifier(keys[i]), comparison))
+		args = append(args, bound[keys[i]])
+
+		clauses = append(clauses, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	return strings.Join(clauses, " OR "), args
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"migration-tool-go/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// normalizedDatetimeLayout is the canonical form of timestamps, trailing fractional zeros are dropped
+const normalizedDatetimeLayout = "2006-01-02 15:04:05.999999"
+
+// normalizeSourceValue converts a value read from PostgreSQL into the canonical text compared during
+// validation. It mirrors how the value is serialized for Stream Load and read back from Doris.
+func normalizeSourceValue(dataType string, value any) (string, bool) {
+	if pointer, ok := value.(*any); ok {
+		if pointer == nil {
+			return "", false
+		}
+		value = *pointer
+	}
+	if value == nil {
+		return "", false
+	}
+
+	if elementType, ok := strings.CutPrefix(dataType, "_"); ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value), true
+		}
+		return canonicalArray(elementType, string(data)), true
+	}
+
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	case int16:
+		return strconv.FormatInt(int64(v), 10), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case pgtype.Numeric:
+		data, err := v.MarshalJSON()
+		if err != nil {
+			return fmt.Sprint(value), true
+		}
+		return trimDecimal(strings.Trim(string(data), `"`)), true
+	case time.Time:
+		switch dataType {
+		case "date":
+			return v.Format(time.DateOnly), true
+		case "timestamptz":
+			return v.UTC().Format(normalizedDatetimeLayout), true
+		}
+		return v.Format(normalizedDatetimeLayout), true
+	case []byte:
+		// encoding/json serializes bytea as base64 for Stream Load
+		return base64.StdEncoding.EncodeToString(v), true
+	case string:
+		// Converted values are already in the form Doris reads, they normalize like the values read back
+		return normalizeDestinationValue(dataType, v), true
+	}
+
+	return fmt.Sprint(value), true
+}
+
+// normalizeDestinationValue converts the text of a value read from Doris into its canonical form
+func normalizeDestinationValue(dataType string, value string) string {
+	if elementType, ok := strings.CutPrefix(dataType, "_"); ok {
+		return canonicalArray(elementType, value)
+	}
+
+	switch dataType {
+	case "bool":
+		switch strings.ToLower(value) {
+		case "true":
+			return "1"
+		case "false":
+			return "0"
+		}
+	case "float4":
+		if f, err := strconv.ParseFloat(value, 32); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 32)
+		}
+	case "float8":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case "numeric":
+		return trimDecimal(value)
+	case "timestamp", "timestamptz":
+		if t, err := time.Parse(normalizedDatetimeLayout, value); err == nil {
+			return t.Format(normalizedDatetimeLayout)
+		}
+		// Key values of timestamptz carry the offset of the configured time zone, their wall clock is what Doris holds
+		if t, err := time.Parse(repository.TimestamptzKeyLayout, value); err == nil {
+			return t.Format(normalizedDatetimeLayout)
+		}
+	case "json", "jsonb":
+		return canonicalJSON(value)
+	}
+
+	return value
+}
+
+// canonicalJSON re-encodes a JSON document with sorted keys and no insignificant whitespace
+func canonicalJSON(value string) string {
+	var document any
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return value
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
+
+// canonicalArray re-encodes a JSON array with its elements normalized as values of the element type,
+// so that numbers and the strings of converted values compare alike
+func canonicalArray(elementType string, value string) string {
+	var document any
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return value
+	}
+
+	var normalize func(element any) any
+	normalize = func(element any) any {
+		switch v := element.(type) {
+		case []any:
+			for i := range v {
+				v[i] = normalize(v[i])
+			}
+			return v
+		case bool:
+			if v {
+				return "1"
+			}
+			return "0"
+		case json.Number:
+			return normalizeDestinationValue(elementType, v.String())
+		case string:
+			return normalizeDestinationValue(elementType, v)
+		}
+		return element
+	}
+
+	data, err := json.Marshal(normalize(document))
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
+
+// trimDecimal drops the trailing fractional zeros of a decimal number
+func trimDecimal(value string) string {
+	if !strings.Contains(value, ".") {
+		return value
+	}
+	value = strings.TrimRight(value, "0")
+	value = strings.TrimSuffix(value, ".")
+	if value == "-0" {
+		return "0"
+	}
+	return value
+}